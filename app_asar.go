@@ -1,12 +1,12 @@
 package main
 
 import (
-	"encoding/binary"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
+	"regexp"
+	"vencordinstaller/asar"
 )
 
 var PackageJson = `{
@@ -14,64 +14,109 @@ var PackageJson = `{
 	"main": "index.js"
 }`
 
-type asarEntry struct {
-	Size   int32  `json:"size"`
-	Offset string `json:"offset"`
+// BuildAppAsar builds a stub app.asar that loads patcherPath instead of Discord
+func BuildAppAsar(patcherPath string) ([]byte, error) {
+	patcherPathB, _ := json.Marshal(patcherPath)
+	indexJsContents := "require(" + string(patcherPathB) + ")"
+
+	var buf bytes.Buffer
+	err := asar.Write(&buf, "", []asar.File{
+		{Name: "index.js", Data: []byte(indexJsContents)},
+		{Name: "package.json", Data: []byte(PackageJson)},
+	}, asar.Options{Integrity: true})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to build app.asar: %w", err)
+	}
+	return buf.Bytes(), nil
 }
 
-// Ported from https://github.com/GeopJr/asar-cr/blob/cd7695b7c913bf921d9fb6600eaeb1400e3ba225/src/asar-cr/pack.cr#L61
+// hasStubLayout reports whether the asar at asarPath is laid out exactly like the stubs BuildAppAsar builds,
+// requiring target. Older versions of the installer built the same files
+func hasStubLayout(asarPath, target string) bool {
+	archive, err := asar.Open(asarPath)
+	if err != nil {
+		return false
+	}
+	defer archive.Close()
 
-func WriteAppAsar(outFile string, patcherPath string) error {
-	header := make(map[string]map[string]asarEntry)
-	files := make(map[string]asarEntry)
-	header["files"] = files
+	if names := archive.List(); len(names) != 2 || names[0] != "index.js" || names[1] != "package.json" {
+		return false
+	}
+	var pkg appPackageJson
+	if b, err := archive.ReadFile("package.json"); err != nil || json.Unmarshal(b, &pkg) != nil {
+		return false
+	}
+	if pkg != (appPackageJson{Name: "discord", Main: "index.js"}) {
+		return false
+	}
+	indexJs, err := archive.ReadFile("index.js")
+	if err != nil {
+		return false
+	}
+	targetB, _ := json.Marshal(target)
+	return string(bytes.TrimSpace(indexJs)) == "require("+string(targetB)+")"
+}
 
-	fileContents := ""
+type appPackageJson struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Main        string `json:"main"`
+}
 
-	patcherPathB, _ := json.Marshal(patcherPath)
-	indexJsContents := "require(" + string(patcherPathB) + ")"
-	indexJsBytes := len([]byte(indexJsContents))
-	fileContents += indexJsContents
-	files["index.js"] = asarEntry{
-		Size:   int32(indexJsBytes),
-		Offset: "0",
+// ReadAppEntry reads package.json and the main script it points to from an app asar.
+// Only these two files are read, no matter how big the archive is
+func ReadAppEntry(asarPath string) (pkg appPackageJson, mainJs []byte, err error) {
+	archive, err := asar.Open(asarPath)
+	if err != nil {
+		return
 	}
+	defer archive.Close()
 
-	fileContents += PackageJson
-	files["package.json"] = asarEntry{
-		Size:   int32(len([]byte(PackageJson))),
-		Offset: strconv.Itoa(indexJsBytes),
+	pkgBytes, err := archive.ReadFile("package.json")
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(pkgBytes, &pkg); err != nil {
+		err = fmt.Errorf("Failed to parse package.json of %s: %w", asarPath, err)
+		return
 	}
 
-	headerBytes, _ := json.Marshal(header)
-	headerString := string(headerBytes)
-	headerStringSize := uint32(len(headerString))
-	dataSize := uint32(4)
-	alignedSize := (headerStringSize + dataSize - 1) & ^(dataSize - 1)
-	headerSize := alignedSize + 8
-	headerObjectSize := alignedSize + dataSize
-	diff := alignedSize - headerStringSize
-	if diff > 0 {
-		headerString += strings.Repeat("0", int(diff))
+	main := pkg.Main
+	if main == "" {
+		main = "index.js"
 	}
+	mainJs, err = archive.ReadFile(main)
+	return
+}
+
+var stubRequireRe = regexp.MustCompile(`^\s*require\(("(?:[^"\\]|\\.)*")\);?\s*$`)
 
-	f, err := os.Create(outFile)
+// ReadStubTarget returns the path required by a stub app.asar like the ones BuildAppAsar builds.
+// If the asar is a real Discord asar (or anything else that isn't a stub), it returns ""
+func ReadStubTarget(asarPath string) (string, error) {
+	_, mainJs, err := ReadAppEntry(asarPath)
 	if err != nil {
-		return fmt.Errorf("Failed to create %s: %w", outFile, err)
+		return "", err
 	}
-	defer f.Close()
 
-	for _, n := range []uint32{dataSize, headerSize, headerObjectSize, headerStringSize} {
-		if err = binary.Write(f, binary.LittleEndian, int32(n)); err != nil {
-			return fmt.Errorf("Failed to write asar bytes: %w", err)
-		}
+	match := stubRequireRe.FindSubmatch(mainJs)
+	if match == nil {
+		return "", nil
 	}
 
-	for _, s := range []string{headerString, fileContents} {
-		if _, err = f.WriteString(s); err != nil {
-			return fmt.Errorf("Failed to write asar data: %w", err)
-		}
+	var target string
+	if err = json.Unmarshal(match[1], &target); err != nil {
+		return "", errors.New("Failed to parse stub require path: " + err.Error())
 	}
+	return target, nil
+}
 
-	return nil
+// IsStubAsar reports whether the asar at asarPath is a stub that loads some patcher instead of Discord
+func IsStubAsar(asarPath string) bool {
+	target, err := ReadStubTarget(asarPath)
+	if err != nil {
+		Log.Debug("Failed to read", asarPath+":", err)
+	}
+	Log.Debug("Checking if", asarPath, "is a stub:", Ternary(target != "", "Yes, requires "+target, "No"))
+	return target != ""
 }
@@ -0,0 +1,111 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"sort"
+)
+
+type DiffStatus string
+
+const (
+	DiffSame    DiffStatus = "same"
+	DiffAdded   DiffStatus = "added"
+	DiffRemoved DiffStatus = "removed"
+	DiffChanged DiffStatus = "changed"
+)
+
+// DiffEntry describes how one entry differs between two archives. Sizes and hashes are empty
+// for directories and for the side the entry doesn't exist on
+type DiffEntry struct {
+	Name    string     `json:"name"`
+	Status  DiffStatus `json:"status"`
+	OldSize int64      `json:"oldSize,omitempty"`
+	NewSize int64      `json:"newSize,omitempty"`
+	OldHash string     `json:"oldHash,omitempty"`
+	NewHash string     `json:"newHash,omitempty"`
+}
+
+// Hash returns the sha256 of the file at name. The hash is always computed from the actual data,
+// never taken from the integrity block in the header, so tampered archives show up as different
+func (a *Archive) Hash(name string) (string, error) {
+	r, err := a.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	integrity, _, err := ComputeIntegrity(r)
+	if err != nil {
+		return "", err
+	}
+	return integrity.Hash, nil
+}
+
+type diffInfo struct {
+	entry *Entry
+	size  int64
+	hash  string
+}
+
+func collectDiffInfo(a *Archive) (map[string]diffInfo, error) {
+	infos := make(map[string]diffInfo)
+	err := a.Walk(func(name string, e *Entry) error {
+		info := diffInfo{entry: e}
+		switch {
+		case e.IsLink():
+			info.hash = "link:" + e.Link
+		case !e.IsDir():
+			hash, err := a.Hash(name)
+			if err != nil {
+				return err
+			}
+			info.size, info.hash = e.Size, hash
+		}
+		infos[name] = info
+		return nil
+	})
+	return infos, err
+}
+
+// Diff compares every entry of oldArchive with newArchive by size and content hash.
+// The result is sorted by name and includes unchanged entries
+func Diff(oldArchive, newArchive *Archive) ([]DiffEntry, error) {
+	oldInfos, err := collectDiffInfo(oldArchive)
+	if err != nil {
+		return nil, err
+	}
+	newInfos, err := collectDiffInfo(newArchive)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []DiffEntry
+	for name, o := range oldInfos {
+		d := DiffEntry{Name: name, OldSize: o.size, OldHash: o.hash}
+		if n, ok := newInfos[name]; !ok {
+			d.Status = DiffRemoved
+		} else {
+			d.NewSize, d.NewHash = n.size, n.hash
+			d.Status = DiffChanged
+			if o.entry.IsDir() == n.entry.IsDir() && o.hash == n.hash {
+				d.Status = DiffSame
+			}
+		}
+		result = append(result, d)
+	}
+	for name, n := range newInfos {
+		if _, ok := oldInfos[name]; !ok {
+			result = append(result, DiffEntry{Name: name, Status: DiffAdded, NewSize: n.size, NewHash: n.hash})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
@@ -0,0 +1,77 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Extract writes the whole archive to destDir, including unpacked files
+func (a *Archive) Extract(destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	return a.Walk(func(name string, e *Entry) error {
+		if err := checkName(name); err != nil {
+			return fmt.Errorf("Refusing to extract %q: path escapes the destination", name)
+		}
+		outPath := filepath.Join(destDir, filepath.FromSlash(name))
+
+		switch {
+		case e.IsDir():
+			return os.MkdirAll(outPath, 0755)
+		case e.IsLink():
+			// Links are relative to the archive root and must stay inside of it, or the extracted link could
+			// point anywhere on the system
+			link := path.Clean(filepath.ToSlash(e.Link))
+			if path.IsAbs(link) || filepath.IsAbs(e.Link) || filepath.VolumeName(e.Link) != "" || link == ".." || strings.HasPrefix(link, "../") {
+				return fmt.Errorf("Refusing to extract %q: its link target %q is outside the archive", name, e.Link)
+			}
+			target, err := filepath.Rel(filepath.Dir(filepath.FromSlash(name)), filepath.FromSlash(link))
+			if err != nil {
+				return err
+			}
+			if runtime.GOOS == "windows" {
+				// Creating symlinks needs admin on Windows, just copy the file instead
+				return a.extractFile(name, outPath, e)
+			}
+			return os.Symlink(target, outPath)
+		default:
+			return a.extractFile(name, outPath, e)
+		}
+	})
+}
+
+func (a *Archive) extractFile(name, outPath string, e *Entry) error {
+	r, err := a.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if target, err := a.Resolve(name); err == nil {
+		e = target
+	}
+
+	out, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644|fileModeExec(e.Executable))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, r); err != nil {
+		return fmt.Errorf("Failed to extract %s: %w", name, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,131 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	data := []byte("hello")
+	file := func() *Entry { return &Entry{Size: int64(len(data)), Offset: "0"} }
+
+	tests := []struct {
+		name string
+		root *Entry
+		// error if empty. Otherwise the extracted files
+		want []string
+	}{
+		{
+			name: "regular",
+			root: &Entry{Files: map[string]*Entry{
+				"lib":   {Files: map[string]*Entry{"a.js": file()}},
+				"empty": {Files: map[string]*Entry{}},
+				"b.js":  file(),
+			}},
+			want: []string{"b.js", "empty", "lib", "lib/a.js"},
+		},
+		{
+			name: "link inside",
+			root: &Entry{Files: map[string]*Entry{
+				"lib":  {Files: map[string]*Entry{"a.js": file(), "up.js": {Link: "b.js"}}},
+				"b.js": file(),
+			}},
+			want: []string{"b.js", "lib", "lib/a.js", "lib/up.js"},
+		},
+		{
+			name: "dot dot name",
+			root: &Entry{Files: map[string]*Entry{"..": {Files: map[string]*Entry{"evil.js": file()}}}},
+		},
+		{
+			name: "backslash name",
+			root: &Entry{Files: map[string]*Entry{`..\evil.js`: file()}},
+		},
+		{
+			name: "slash name",
+			root: &Entry{Files: map[string]*Entry{"../evil.js": file()}},
+		},
+		{
+			name: "absolute link",
+			root: &Entry{Files: map[string]*Entry{"passwd": {Link: "/etc/passwd"}}},
+		},
+		{
+			name: "escaping link",
+			root: &Entry{Files: map[string]*Entry{"x": {Link: "../x"}}},
+		},
+		{
+			name: "escaping link after cleaning",
+			root: &Entry{Files: map[string]*Entry{"x": {Link: "lib/../../x"}}},
+		},
+		{
+			name: "link to parent",
+			root: &Entry{Files: map[string]*Entry{"x": {Link: ".."}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Open(writeArchive(t, t.TempDir(), tt.root, data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Close()
+
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "out")
+			err = a.Extract(dest)
+
+			if tt.want == nil {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				// Nothing may end up next to the destination
+				if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+					t.Errorf("extracting created %d entries next to the destination", len(entries)-1)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			_ = filepath.Walk(dest, func(p string, _ os.FileInfo, _ error) error {
+				if rel, _ := filepath.Rel(dest, p); rel != "." {
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+
+			for _, name := range tt.want {
+				e, _ := a.Lookup(name)
+				if e == nil || e.IsDir() {
+					continue
+				}
+				b, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+				if err != nil || !bytes.Equal(b, data) {
+					t.Errorf("%s is %q (%v), want %q", name, b, err, data)
+				}
+				if e.IsLink() && runtime.GOOS != "windows" {
+					if _, err = os.Readlink(filepath.Join(dest, filepath.FromSlash(name))); err != nil {
+						t.Errorf("%s should be a symlink: %v", name, err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+const IntegrityBlockSize = 4 << 20
+
+// Integrity is Electron's per file integrity block: a sha256 of the whole file plus one per 4 MiB block.
+// See https://github.com/electron/asar/blob/main/src/integrity.ts
+type Integrity struct {
+	Algorithm string   `json:"algorithm"`
+	Hash      string   `json:"hash"`
+	BlockSize int      `json:"blockSize"`
+	Blocks    []string `json:"blocks"`
+}
+
+// ComputeIntegrity hashes everything r returns and also reports how many bytes that was
+func ComputeIntegrity(r io.Reader) (*Integrity, int64, error) {
+	fileHash := sha256.New()
+	blockHash := sha256.New()
+	integrity := &Integrity{
+		Algorithm: "SHA256",
+		BlockSize: IntegrityBlockSize,
+		Blocks:    []string{},
+	}
+
+	var total int64
+	buf := make([]byte, 64*1024)
+	blockFill := 0
+	for {
+		n, err := r.Read(buf)
+		chunk := buf[:n]
+		total += int64(n)
+		fileHash.Write(chunk)
+
+		for len(chunk) > 0 {
+			take := IntegrityBlockSize - blockFill
+			if take > len(chunk) {
+				take = len(chunk)
+			}
+			blockHash.Write(chunk[:take])
+			blockFill += take
+			chunk = chunk[take:]
+			if blockFill == IntegrityBlockSize {
+				integrity.Blocks = append(integrity.Blocks, hex.EncodeToString(blockHash.Sum(nil)))
+				blockHash.Reset()
+				blockFill = 0
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, total, err
+		}
+	}
+
+	// Like Electron, always end with the hash of the last (possibly empty) block
+	integrity.Blocks = append(integrity.Blocks, hex.EncodeToString(blockHash.Sum(nil)))
+	integrity.Hash = hex.EncodeToString(fileHash.Sum(nil))
+	return integrity, total, nil
+}
@@ -0,0 +1,77 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestComputeIntegrity(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		// including the trailing block Electron always adds, which is empty for multiples of the block size
+		blocks int
+	}{
+		{"empty", 0, 1},
+		{"one byte", 1, 1},
+		{"just under a block", IntegrityBlockSize - 1, 1},
+		{"exactly one block", IntegrityBlockSize, 2},
+		{"just over a block", IntegrityBlockSize + 1, 2},
+		{"exactly two blocks", 2 * IntegrityBlockSize, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.size)
+			for i := range data {
+				data[i] = byte(i * 7)
+			}
+
+			// short reads, so blocks are filled across several of them
+			integrity, total, err := ComputeIntegrity(iotest.HalfReader(bytes.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != int64(tt.size) {
+				t.Errorf("read %d bytes, want %d", total, tt.size)
+			}
+			if integrity.Algorithm != "SHA256" || integrity.BlockSize != IntegrityBlockSize {
+				t.Errorf("unexpected algorithm %s or block size %d", integrity.Algorithm, integrity.BlockSize)
+			}
+			if integrity.Hash != sha256Hex(data) {
+				t.Errorf("hash is %s, want %s", integrity.Hash, sha256Hex(data))
+			}
+
+			var want []string
+			for start := 0; ; start += IntegrityBlockSize {
+				end := start + IntegrityBlockSize
+				if end > len(data) {
+					want = append(want, sha256Hex(data[start:]))
+					break
+				}
+				want = append(want, sha256Hex(data[start:end]))
+			}
+			if len(want) != tt.blocks {
+				t.Fatalf("test expects %d blocks, but the reference has %d", tt.blocks, len(want))
+			}
+			if !reflect.DeepEqual(integrity.Blocks, want) {
+				t.Errorf("blocks are %v, want %v", integrity.Blocks, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Electron never writes headers anywhere close to this, it only exists so a corrupt file
+// doesn't make us allocate gigabytes
+const maxHeaderSize = 256 << 20
+
+// Symlinks can point at other symlinks. Give up after this many hops
+const maxLinkDepth = 32
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// Entry is a single node of the archive header. Directories have Files set, symlinks have Link set,
+// everything else is a regular file whose data lives at Offset (relative to the end of the header)
+// or, if Unpacked is set, next to the archive in <archive>.unpacked
+type Entry struct {
+	Files      map[string]*Entry `json:"files,omitempty"`
+	Size       int64             `json:"size"`
+	Offset     string            `json:"offset,omitempty"`
+	Unpacked   bool              `json:"unpacked,omitempty"`
+	Executable bool              `json:"executable,omitempty"`
+	Link       string            `json:"link,omitempty"`
+	Integrity  *Integrity        `json:"integrity,omitempty"`
+}
+
+// MarshalJSON writes entries the way Electron expects them. Directories always have files (even if empty)
+// and nothing else, links only have link, and files always have a size
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	switch {
+	case e.IsLink():
+		return json.Marshal(struct {
+			Link string `json:"link"`
+		}{e.Link})
+	case e.IsDir():
+		return json.Marshal(struct {
+			Files    map[string]*Entry `json:"files"`
+			Unpacked bool              `json:"unpacked,omitempty"`
+		}{e.Files, e.Unpacked})
+	default:
+		type plainEntry Entry
+		return json.Marshal((*plainEntry)(e))
+	}
+}
+
+func (e *Entry) IsDir() bool {
+	return e.Files != nil
+}
+
+func (e *Entry) IsLink() bool {
+	return e.Link != ""
+}
+
+// Archive is an opened asar file. The header is parsed once on Open, file data is only read on demand
+type Archive struct {
+	Path string
+	Root *Entry
+
+	f          *os.File
+	dataOffset int64
+}
+
+// Open parses the header of the asar archive at p
+func Open(p string) (*Archive, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	root, dataOffset, err := readHeader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("Failed to read asar header of %s: %w", p, err)
+	}
+
+	return &Archive{
+		Path:       p,
+		Root:       root,
+		f:          f,
+		dataOffset: dataOffset,
+	}, nil
+}
+
+// The header is two Chromium Pickles. The first one only contains the size of the second one,
+// which holds the json header as a length prefixed string:
+//
+//	uint32 4 | uint32 headerSize | uint32 headerPayloadSize | uint32 jsonSize | json | padding
+func readHeader(r io.ReaderAt) (*Entry, int64, error) {
+	var sizePickle [8]byte
+	if _, err := r.ReadAt(sizePickle[:], 0); err != nil {
+		return nil, 0, fmt.Errorf("Failed to read size pickle: %w", err)
+	}
+	if payloadSize := binary.LittleEndian.Uint32(sizePickle[:4]); payloadSize != 4 {
+		return nil, 0, fmt.Errorf("Invalid size pickle (payload size %d). Not an asar file?", payloadSize)
+	}
+
+	headerSize := binary.LittleEndian.Uint32(sizePickle[4:])
+	if headerSize < 8 || headerSize > maxHeaderSize {
+		return nil, 0, fmt.Errorf("Invalid header size %d", headerSize)
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 8); err != nil {
+		return nil, 0, fmt.Errorf("Failed to read header pickle: %w", err)
+	}
+
+	jsonSize := binary.LittleEndian.Uint32(header[4:8])
+	if uint64(jsonSize) > uint64(headerSize-8) {
+		return nil, 0, fmt.Errorf("Header string length %d exceeds header size %d", jsonSize, headerSize)
+	}
+
+	var root Entry
+	if err := json.Unmarshal(header[8:8+jsonSize], &root); err != nil {
+		return nil, 0, fmt.Errorf("Failed to parse header json: %w", err)
+	}
+	if !root.IsDir() {
+		return nil, 0, errors.New("Header has no files")
+	}
+
+	return &root, 8 + int64(headerSize), nil
+}
+
+func (a *Archive) Close() error {
+	return a.f.Close()
+}
+
+// UnpackedDir is the directory Electron looks for unpacked entries in
+func (a *Archive) UnpackedDir() string {
+	return a.Path + ".unpacked"
+}
+
+// checkName returns an error if name, as found in the header, leads outside the archive once it is turned
+// into a path on disk, like names with .. or backslashes do
+func checkName(name string) error {
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || strings.ContainsAny(part, `/\`) || filepath.VolumeName(part) != "" {
+			return fmt.Errorf("%q leads outside the archive", name)
+		}
+	}
+	return nil
+}
+
+func splitPath(name string) []string {
+	name = strings.Trim(filepath.ToSlash(name), "/")
+	if name == "" || name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// Lookup returns the entry at name without following a symlink in the last element
+func (a *Archive) Lookup(name string) (*Entry, error) {
+	_, e, err := a.lookup(name, 0)
+	return e, err
+}
+
+// lookup also returns the canonical name of the entry, which differs from name if name
+// passes through a directory symlink
+func (a *Archive) lookup(name string, depth int) (string, *Entry, error) {
+	if depth > maxLinkDepth {
+		return "", nil, &fs.PathError{Op: "lookup", Path: name, Err: errTooManyLinks}
+	}
+
+	parts := splitPath(name)
+	e := a.Root
+	for i, part := range parts {
+		if e.IsLink() {
+			// directory symlink in the middle of the path, continue from its target
+			target := strings.Join(append(splitPath(e.Link), parts[i:]...), "/")
+			return a.lookup(target, depth+1)
+		}
+		if !e.IsDir() {
+			return "", nil, &fs.PathError{Op: "lookup", Path: name, Err: fs.ErrNotExist}
+		}
+		child, ok := e.Files[part]
+		if !ok {
+			return "", nil, &fs.PathError{Op: "lookup", Path: name, Err: fs.ErrNotExist}
+		}
+		e = child
+	}
+	return strings.Join(parts, "/"), e, nil
+}
+
+// Resolve is like Lookup but follows symlinks. Link targets are relative to the archive root
+func (a *Archive) Resolve(name string) (*Entry, error) {
+	_, e, err := a.resolve(name)
+	return e, err
+}
+
+func (a *Archive) resolve(name string) (string, *Entry, error) {
+	name, e, err := a.lookup(name, 0)
+	for depth := 0; err == nil && e.IsLink(); depth++ {
+		if depth > maxLinkDepth {
+			return "", nil, &fs.PathError{Op: "resolve", Path: name, Err: errTooManyLinks}
+		}
+		name, e, err = a.lookup(e.Link, 0)
+	}
+	return name, e, err
+}
+
+// Walk calls fn for every entry in the archive in lexical order, parents before their children.
+// Names are slash separated and relative to the archive root
+func (a *Archive) Walk(fn func(name string, e *Entry) error) error {
+	return walk("", a.Root, fn)
+}
+
+func walk(prefix string, dir *Entry, fn func(name string, e *Entry) error) error {
+	names := make([]string, 0, len(dir.Files))
+	for name := range dir.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		e := dir.Files[name]
+		full := name
+		if prefix != "" {
+			full = prefix + "/" + name
+		}
+		if err := fn(full, e); err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if err := walk(full, e, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// List returns the names of all entries in the archive, see Walk
+func (a *Archive) List() []string {
+	var names []string
+	_ = a.Walk(func(name string, _ *Entry) error {
+		names = append(names, name)
+		return nil
+	})
+	return names
+}
+
+// Open returns a reader for the contents of the file at name, following symlinks.
+// Packed files are streamed straight from the archive without reading anything else
+func (a *Archive) Open(name string) (io.ReadCloser, error) {
+	name, e, err := a.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	if e.Unpacked {
+		if err = checkName(name); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return os.Open(filepath.Join(a.UnpackedDir(), filepath.FromSlash(name)))
+	}
+
+	offset, err := strconv.ParseInt(e.Offset, 10, 64)
+	if err != nil || offset < 0 || e.Size < 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("invalid offset %q or size %d", e.Offset, e.Size)}
+	}
+
+	return io.NopCloser(io.NewSectionReader(a.f, a.dataOffset+offset, e.Size)), nil
+}
+
+// ReadFile reads the whole file at name. Only use this for small files
+func (a *Archive) ReadFile(name string) ([]byte, error) {
+	r, err := a.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if e, _ := a.Resolve(name); e != nil && !e.Unpacked && int64(len(b)) != e.Size {
+		return nil, fmt.Errorf("%s: unexpected end of archive, expected %d bytes but got %d", name, e.Size, len(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,149 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeArchive writes an archive with the given header and data to dir, bypassing the checks of Pack
+func writeArchive(t *testing.T, dir string, root *Entry, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, root); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(data)
+	p := filepath.Join(dir, "app.asar")
+	if err := os.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestReadHeaderInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"too short", []byte{4, 0, 0}},
+		{"wrong size pickle", []byte{5, 0, 0, 0, 8, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0}},
+		{"header too small", []byte{4, 0, 0, 0, 4, 0, 0, 0}},
+		{"header past end", []byte{4, 0, 0, 0, 64, 0, 0, 0, 4, 0, 0, 0}},
+		{"string past header", []byte{4, 0, 0, 0, 8, 0, 0, 0, 4, 0, 0, 0, 9, 0, 0, 0}},
+		{"not json", append([]byte{4, 0, 0, 0, 12, 0, 0, 0, 8, 0, 0, 0, 4, 0, 0, 0}, "html"...)},
+		{"no files", append([]byte{4, 0, 0, 0, 12, 0, 0, 0, 8, 0, 0, 0, 2, 0, 0, 0}, "{}  "...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := readHeader(bytes.NewReader(tt.data)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	unpacked := func(size int) *Entry { return &Entry{Size: int64(size), Unpacked: true} }
+
+	tests := []struct {
+		name string
+		root *Entry
+		// files to create next to the archive
+		files map[string]string
+		open  string
+		// error if empty
+		want string
+	}{
+		{
+			name: "packed",
+			root: &Entry{Files: map[string]*Entry{"index.js": {Size: 5, Offset: "0"}}},
+			open: "index.js",
+			want: "hello",
+		},
+		{
+			name: "packed through link",
+			root: &Entry{Files: map[string]*Entry{"index.js": {Size: 5, Offset: "0"}, "main.js": {Link: "index.js"}}},
+			open: "main.js",
+			want: "hello",
+		},
+		{
+			name:  "unpacked",
+			root:  &Entry{Files: map[string]*Entry{"native": {Files: map[string]*Entry{"a.node": unpacked(6)}}}},
+			files: map[string]string{"app.asar.unpacked/native/a.node": "native"},
+			open:  "native/a.node",
+			want:  "native",
+		},
+		{
+			name:  "unpacked dot dot",
+			root:  &Entry{Files: map[string]*Entry{"..": {Files: map[string]*Entry{"secret": unpacked(6)}}}},
+			files: map[string]string{"secret": "secret"},
+			open:  "../secret",
+		},
+		{
+			name:  "unpacked backslash",
+			root:  &Entry{Files: map[string]*Entry{`..\secret`: unpacked(6)}},
+			files: map[string]string{"secret": "secret"},
+			open:  `..\secret`,
+		},
+		{
+			name: "directory",
+			root: &Entry{Files: map[string]*Entry{"lib": {Files: map[string]*Entry{}}}},
+			open: "lib",
+		},
+		{
+			name: "missing",
+			root: &Entry{Files: map[string]*Entry{}},
+			open: "index.js",
+		},
+		{
+			name: "link loop",
+			root: &Entry{Files: map[string]*Entry{"a": {Link: "b"}, "b": {Link: "a"}}},
+			open: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				p := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			a, err := Open(writeArchive(t, dir, tt.root, []byte("hello")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Close()
+
+			got, err := a.ReadFile(tt.open)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("expected an error, read %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File is a single item to pack. Exactly one of Data, Source, Link or Dir should be set
+type File struct {
+	// Slash separated path inside the archive
+	Name string
+	// In memory contents
+	Data []byte
+	// Path of a file on disk to copy the contents from
+	Source string
+	// Symlink target, relative to the archive root
+	Link string
+	// Directory without any contents. Directories containing files are created implicitly
+	Dir bool
+
+	Executable bool
+	// Store the file in <archive>.unpacked instead of the archive itself
+	Unpacked bool
+}
+
+func (f *File) open() (io.ReadCloser, error) {
+	if f.Source != "" {
+		return os.Open(f.Source)
+	}
+	return io.NopCloser(bytes.NewReader(f.Data)), nil
+}
+
+type Options struct {
+	// Unpack is called for every regular file. If it returns true, the file is stored in
+	// <archive>.unpacked, regardless of File.Unpacked
+	Unpack func(name string) bool
+	// Integrity adds Electron's per file integrity hashes to the header
+	Integrity bool
+}
+
+type pendingFile struct {
+	file  *File
+	entry *Entry
+}
+
+// Pack writes files into a new asar archive at outFile. Unpacked files are written to outFile.unpacked
+func Pack(outFile string, files []File, opts Options) error {
+	root, pending, err := prepare(files, opts)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outFile)
+	if err != nil {
+		return fmt.Errorf("Failed to create %s: %w", outFile, err)
+	}
+	defer out.Close()
+
+	if err = write(out, outFile+".unpacked", root, pending); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// prepare builds the header for files and hashes them. The header has to contain sizes, offsets and hashes before any data
+func prepare(files []File, opts Options) (*Entry, []pendingFile, error) {
+	root := &Entry{Files: map[string]*Entry{}}
+
+	sorted := make([]*File, len(files))
+	for i := range files {
+		sorted[i] = &files[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var pending []pendingFile
+	for _, f := range sorted {
+		parts := splitPath(f.Name)
+		if len(parts) == 0 {
+			return nil, nil, fmt.Errorf("Invalid file name %q", f.Name)
+		}
+
+		dir, err := mkdirAll(root, parts[:len(parts)-1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+
+		name := parts[len(parts)-1]
+		if existing, ok := dir.Files[name]; ok {
+			if f.Dir && existing.IsDir() {
+				continue
+			}
+			return nil, nil, fmt.Errorf("%s: duplicate entry", f.Name)
+		}
+
+		e := &Entry{}
+		switch {
+		case f.Dir:
+			e.Files = map[string]*Entry{}
+		case f.Link != "":
+			e.Link = strings.Join(splitPath(f.Link), "/")
+		default:
+			e.Executable = f.Executable
+			e.Unpacked = f.Unpacked || (opts.Unpack != nil && opts.Unpack(strings.Join(parts, "/")))
+			pending = append(pending, pendingFile{f, e})
+		}
+		dir.Files[name] = e
+	}
+
+	var offset int64
+	for _, p := range pending {
+		r, err := p.file.open()
+		if err != nil {
+			return nil, nil, err
+		}
+		integrity, size, err := ComputeIntegrity(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to read %s: %w", p.file.Name, err)
+		}
+
+		p.entry.Size = size
+		if opts.Integrity {
+			p.entry.Integrity = integrity
+		}
+		if !p.entry.Unpacked {
+			p.entry.Offset = strconv.FormatInt(offset, 10)
+			offset += size
+		}
+	}
+
+	return root, pending, nil
+}
+
+// Write is like Pack, but writes the archive to w. Unpacked files are written to unpackedDir
+func Write(w io.Writer, unpackedDir string, files []File, opts Options) error {
+	root, pending, err := prepare(files, opts)
+	if err != nil {
+		return err
+	}
+	return write(w, unpackedDir, root, pending)
+}
+
+func write(out io.Writer, unpackedDir string, root *Entry, pending []pendingFile) error {
+	if err := writeHeader(out, root); err != nil {
+		return fmt.Errorf("Failed to write asar header: %w", err)
+	}
+
+	for _, p := range pending {
+		if p.entry.Unpacked {
+			if err := writeUnpacked(unpackedDir, p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r, err := p.file.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(out, r, p.entry.Size)
+		_ = r.Close()
+		if err != nil {
+			return fmt.Errorf("Failed to write %s to asar (did it change while packing?): %w", p.file.Name, err)
+		}
+	}
+	return nil
+}
+
+func mkdirAll(root *Entry, parts []string) (*Entry, error) {
+	dir := root
+	for _, part := range parts {
+		child, ok := dir.Files[part]
+		if !ok {
+			child = &Entry{Files: map[string]*Entry{}}
+			dir.Files[part] = child
+		} else if !child.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", part)
+		}
+		dir = child
+	}
+	return dir, nil
+}
+
+func writeHeader(w io.Writer, root *Entry) error {
+	headerJson, err := json.Marshal(root)
+	if err != nil {
+		return err
+	}
+
+	jsonSize := uint32(len(headerJson))
+	alignedSize := (jsonSize + 3) &^ 3
+	headerPayloadSize := alignedSize + 4
+	headerSize := headerPayloadSize + 4
+
+	for _, n := range []uint32{4, headerSize, headerPayloadSize, jsonSize} {
+		if err = binary.Write(w, binary.LittleEndian, n); err != nil {
+			return err
+		}
+	}
+	if _, err = w.Write(headerJson); err != nil {
+		return err
+	}
+	_, err = w.Write(make([]byte, alignedSize-jsonSize))
+	return err
+}
+
+func writeUnpacked(dir string, p pendingFile) error {
+	outFile := filepath.Join(dir, filepath.FromSlash(strings.Join(splitPath(p.file.Name), "/")))
+	if err := os.MkdirAll(filepath.Dir(outFile), 0755); err != nil {
+		return fmt.Errorf("Failed to create %s: %w", filepath.Dir(outFile), err)
+	}
+
+	r, err := p.file.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fs.FileMode(0644)|fileModeExec(p.entry.Executable))
+	if err != nil {
+		return fmt.Errorf("Failed to create %s: %w", outFile, err)
+	}
+	defer out.Close()
+
+	if _, err = io.CopyN(out, r, p.entry.Size); err != nil {
+		return fmt.Errorf("Failed to write %s: %w", outFile, err)
+	}
+	return out.Close()
+}
+
+func fileModeExec(executable bool) fs.FileMode {
+	if executable {
+		return 0111
+	}
+	return 0
+}
+
+// PackMap packs in memory files, keyed by their slash separated name
+func PackMap(outFile string, files map[string][]byte, opts Options) error {
+	list := make([]File, 0, len(files))
+	for name, data := range files {
+		list = append(list, File{Name: name, Data: data})
+	}
+	return Pack(outFile, list, opts)
+}
+
+// PackDir packs the directory tree at srcDir. Symlinks pointing inside srcDir are kept as links,
+// symlinks pointing outside of it are replaced with the files they point to
+func PackDir(srcDir, outFile string, opts Options) error {
+	srcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return err
+	}
+	realSrcDir, err := filepath.EvalSymlinks(srcDir)
+	if err != nil {
+		return err
+	}
+
+	var files []File
+	err = filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return err
+			}
+			if relTarget, err := filepath.Rel(realSrcDir, target); err == nil && relTarget != ".." && !strings.HasPrefix(relTarget, ".."+string(filepath.Separator)) {
+				files = append(files, File{Name: name, Link: filepath.ToSlash(relTarget)})
+				return nil
+			}
+			if isDirectory(target) {
+				return fmt.Errorf("%s links to directory %s outside of %s", p, target, srcDir)
+			}
+		}
+
+		if d.IsDir() {
+			files = append(files, File{Name: name, Dir: true})
+			return nil
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", p)
+		}
+		files = append(files, File{
+			Name:       name,
+			Source:     p,
+			Executable: runtime.GOOS != "windows" && info.Mode()&0100 != 0,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to read %s: %w", srcDir, err)
+	}
+	if len(files) == 0 {
+		return errors.New(srcDir + " is empty")
+	}
+
+	return Pack(outFile, files, opts)
+}
+
+func isDirectory(p string) bool {
+	s, err := os.Stat(p)
+	return err == nil && s.IsDir()
+}
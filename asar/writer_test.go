@@ -0,0 +1,175 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package asar
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		root *Entry
+	}{
+		{"empty", &Entry{Files: map[string]*Entry{}}},
+		// names of different lengths, so the json needs 0 to 3 bytes of padding
+		{"padding 1", &Entry{Files: map[string]*Entry{"a": {Size: 1, Offset: "0"}}}},
+		{"padding 2", &Entry{Files: map[string]*Entry{"ab": {Size: 1, Offset: "0"}}}},
+		{"padding 3", &Entry{Files: map[string]*Entry{"abc": {Size: 1, Offset: "0"}}}},
+		{"padding 4", &Entry{Files: map[string]*Entry{"abcd": {Size: 1, Offset: "0"}}}},
+		{"nested", &Entry{Files: map[string]*Entry{
+			"index.js":     {Size: 12, Offset: "0", Executable: true},
+			"empty":        {Files: map[string]*Entry{}},
+			"link":         {Link: "lib/main.js"},
+			"native.node":  {Size: 3, Unpacked: true},
+			"package.json": {Size: 0, Offset: "12"},
+			"lib": {Files: map[string]*Entry{
+				"main.js": {Size: 5, Offset: "12", Integrity: &Integrity{Algorithm: "SHA256", Hash: "x", BlockSize: IntegrityBlockSize, Blocks: []string{"x"}}},
+			}},
+		}}},
+		{"unicode", &Entry{Files: map[string]*Entry{"日本語.js": {Size: 2, Offset: "0"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeHeader(&buf, tt.root); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len()%4 != 0 {
+				t.Errorf("header is %d bytes, not aligned to 4", buf.Len())
+			}
+
+			root, dataOffset, err := readHeader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dataOffset != int64(buf.Len()) {
+				t.Errorf("data offset is %d, want %d", dataOffset, buf.Len())
+			}
+
+			want, _ := json.Marshal(tt.root)
+			got, _ := json.Marshal(root)
+			if !bytes.Equal(got, want) {
+				t.Errorf("header changed in the round trip\ngot:  %s\nwant: %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []File
+		opts  Options
+		// contents by name, read back through the archive
+		want map[string]string
+	}{
+		{
+			name:  "single file",
+			files: []File{{Name: "index.js", Data: []byte("require('x')")}},
+			want:  map[string]string{"index.js": "require('x')"},
+		},
+		{
+			name:  "empty file",
+			files: []File{{Name: "empty.js"}},
+			want:  map[string]string{"empty.js": ""},
+		},
+		{
+			name: "nested with integrity",
+			files: []File{
+				{Name: "package.json", Data: []byte(`{"main":"lib/index.js"}`)},
+				{Name: "lib/index.js", Data: []byte("module.exports = 1")},
+				{Name: "lib/deep/x.js", Data: []byte("x")},
+			},
+			opts: Options{Integrity: true},
+			want: map[string]string{"package.json": `{"main":"lib/index.js"}`, "lib/index.js": "module.exports = 1", "lib/deep/x.js": "x"},
+		},
+		{
+			name: "link",
+			files: []File{
+				{Name: "lib/index.js", Data: []byte("a")},
+				{Name: "index.js", Link: "lib/index.js"},
+			},
+			want: map[string]string{"lib/index.js": "a", "index.js": "a"},
+		},
+		{
+			name: "unpacked",
+			files: []File{
+				{Name: "index.js", Data: []byte("packed")},
+				{Name: "native/a.node", Data: []byte("by file"), Unpacked: true},
+				{Name: "native/b.node", Data: []byte("by option")},
+			},
+			opts: Options{Unpack: func(name string) bool { return strings.HasSuffix(name, ".node") }},
+			want: map[string]string{"index.js": "packed", "native/a.node": "by file", "native/b.node": "by option"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "app.asar")
+			if err := Pack(p, tt.files, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			a, err := Open(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Close()
+
+			for name, want := range tt.want {
+				got, err := a.ReadFile(name)
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+				} else if string(got) != want {
+					t.Errorf("%s is %q, want %q", name, got, want)
+				}
+			}
+
+			for _, f := range tt.files {
+				e, err := a.Lookup(f.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if f.Link != "" {
+					if e.Link != f.Link {
+						t.Errorf("%s links to %q, want %q", f.Name, e.Link, f.Link)
+					}
+					continue
+				}
+
+				unpacked := f.Unpacked || (tt.opts.Unpack != nil && tt.opts.Unpack(f.Name))
+				if e.Unpacked != unpacked {
+					t.Errorf("%s: unpacked is %v, want %v", f.Name, e.Unpacked, unpacked)
+				}
+				if unpacked != (e.Offset == "") {
+					t.Errorf("%s: offset %q, but unpacked is %v", f.Name, e.Offset, unpacked)
+				}
+				if onDisk := filepath.Join(p+".unpacked", filepath.FromSlash(f.Name)); unpacked {
+					if b, err := os.ReadFile(onDisk); err != nil || string(b) != string(f.Data) {
+						t.Errorf("%s: unpacked copy is %q (%v), want %q", f.Name, b, err, f.Data)
+					}
+				} else if _, err = os.Stat(onDisk); err == nil {
+					t.Errorf("%s was unpacked, but shouldn't be", f.Name)
+				}
+
+				if tt.opts.Integrity {
+					if e.Integrity == nil || e.Integrity.Hash != sha256Hex(f.Data) {
+						t.Errorf("%s: wrong integrity %+v", f.Name, e.Integrity)
+					}
+				} else if e.Integrity != nil {
+					t.Errorf("%s has integrity, but it wasn't asked for", f.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	path "path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backup is a pristine copy of a Discord install's app.asar, taken right before we patched that Discord version
+// for the first time. Each backup lives in its own folder in backupsDir() with a backup.json manifest
+type Backup struct {
+	Id               string    `json:"id"`
+	Install          string    `json:"install"`
+	Branch           string    `json:"branch"`
+	Version          string    `json:"version"`
+	Sha256           string    `json:"sha256"`
+	UnpackedSha256   string    `json:"unpackedSha256,omitempty"`
+	Size             int64     `json:"size"`
+	IsSystemElectron bool      `json:"isSystemElectron"`
+	Created          time.Time `json:"created"`
+
+	dir string
+}
+
+func backupsDir() string {
+	return path.Join(BaseDir, "backups")
+}
+
+func (b *Backup) asarFile() string {
+	return path.Join(b.dir, "app.asar")
+}
+
+func (b *Backup) String() string {
+	//goland:noinspection GoDeprecation
+	return fmt.Sprintf("%s %s (%s) - %s, %s", strings.Title(b.Branch), b.Version, b.Sha256[:12], b.Install, b.Created.Format("2006-01-02 15:04"))
+}
+
+var unsafeIdChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// discordVersion returns the version of the installed Discord, or "" if it can't be determined
+func (di *DiscordInstall) discordVersion() string {
+	resources := path.Join(di.appPath, "..")
+
+	// Windows: %LOCALAPPDATA%/Discord/app-1.0.9013/resources
+	if appDir := path.Base(path.Dir(resources)); strings.HasPrefix(appDir, "app-") {
+		return appDir[len("app-"):]
+	}
+
+	var buildInfo struct {
+		Version string `json:"version"`
+	}
+	for _, dir := range []string{resources, di.asarDir()} {
+		if b, err := os.ReadFile(path.Join(dir, "build_info.json")); err == nil && json.Unmarshal(b, &buildInfo) == nil && buildInfo.Version != "" {
+			return buildInfo.Version
+		}
+	}
+	return ""
+}
+
+// validate checks the fields of a manifest that everything else relies on, like being able to shorten the hash
+func (b *Backup) validate() error {
+	if b.Id == "" || unsafeIdChars.MatchString(b.Id) {
+		return fmt.Errorf("invalid id %q", b.Id)
+	}
+	if !sha256Re.MatchString(b.Sha256) {
+		return fmt.Errorf("invalid sha256 %q", b.Sha256)
+	}
+	if b.UnpackedSha256 != "" && !sha256Re.MatchString(b.UnpackedSha256) {
+		return fmt.Errorf("invalid unpackedSha256 %q", b.UnpackedSha256)
+	}
+	return nil
+}
+
+// ListBackups returns all backups, newest first
+func ListBackups() []*Backup {
+	dir := backupsDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			Log.Warn("Failed to read backups dir", dir+":", err)
+		}
+		return nil
+	}
+
+	var backups []*Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		manifest := path.Join(dir, entry.Name(), "backup.json")
+		b, err := os.ReadFile(manifest)
+		if err != nil {
+			Log.Debug("Ignoring unfinished backup", path.Join(dir, entry.Name())+":", err)
+			continue
+		}
+
+		var backup Backup
+		if err = json.Unmarshal(b, &backup); err != nil {
+			Log.Warn("Ignoring corrupt backup manifest", manifest+":", err)
+			continue
+		}
+		if err = backup.validate(); err != nil {
+			Log.Warn("Ignoring invalid backup manifest", manifest+":", err)
+			continue
+		}
+		backup.dir = path.Join(dir, entry.Name())
+		backups = append(backups, &backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+	return backups
+}
+
+// BackupsFor returns the backups of the install at any of installPaths, newest first
+func BackupsFor(installPaths ...string) []*Backup {
+	return SliceFilter(ListBackups(), func(b *Backup) bool {
+		return SliceContains(installPaths, b.Install)
+	})
+}
+
+func FindBackup(id string) *Backup {
+	for _, b := range ListBackups() {
+		if b.Id == id {
+			return b
+		}
+	}
+	return nil
+}
+
+// planBackup adds steps to plan that back up the vanilla app.asar of di, unless there already is a backup
+// of this exact file. Stubs and OpenAsar are never backed up since they aren't what Discord shipped
+func (di *DiscordInstall) planBackup(plan *Plan) error {
+	appAsar := di.appAsar()
+
+	if isOpenAsarFile(appAsar) || IsStubAsar(appAsar) {
+		Log.Debug("Not backing up", appAsar, "as it isn't vanilla")
+		return nil
+	}
+
+	hash, err := HashPath(appAsar)
+	if err != nil {
+		return fmt.Errorf("Failed to hash %s: %w", appAsar, err)
+	}
+	for _, b := range BackupsFor(di.Paths()...) {
+		if b.Sha256 == hash {
+			Log.Debug("Backup", b.Id, "already has", appAsar)
+			return nil
+		}
+	}
+
+	version := di.discordVersion()
+	backup := &Backup{
+		Id:               unsafeIdChars.ReplaceAllString(di.branch+"-"+Ternary(version != "", version, "unknown")+"-"+hash[:8], "_"),
+		Install:          di.path,
+		Branch:           di.branch,
+		Version:          version,
+		Sha256:           hash,
+		IsSystemElectron: di.isSystemElectron,
+		Created:          time.Now(),
+	}
+	// The same file might be backed up from another install
+	baseId := backup.Id
+	for i := 2; ExistsFile(path.Join(backupsDir(), backup.Id)); i++ {
+		backup.Id = baseId + "-" + strconv.Itoa(i)
+	}
+	backup.dir = path.Join(backupsDir(), backup.Id)
+	if info, err := os.Stat(appAsar); err == nil {
+		backup.Size = info.Size()
+	}
+
+	plan.Copy(appAsar, backup.asarFile()).Description = "back up the original app.asar"
+	if di.isSystemElectron && ExistsFile(appAsar+".unpacked") {
+		if backup.UnpackedSha256, err = HashPath(appAsar + ".unpacked"); err != nil {
+			return fmt.Errorf("Failed to hash %s: %w", appAsar+".unpacked", err)
+		}
+		plan.Copy(appAsar+".unpacked", backup.asarFile()+".unpacked").Description = "back up the original app.asar.unpacked"
+	}
+
+	// The manifest goes last, backups without one are ignored so a half finished backup never shows up
+	manifest, err := json.MarshalIndent(backup, "", "\t")
+	if err != nil {
+		return err
+	}
+	plan.Write(path.Join(backup.dir, "backup.json"), manifest).Description = "backup manifest"
+	return nil
+}
+
+// Verify checks the backed up files against the hashes recorded when the backup was made
+func (b *Backup) Verify() error {
+	if hash, err := HashPath(b.asarFile()); err != nil {
+		return err
+	} else if hash != b.Sha256 {
+		return fmt.Errorf("Backup %s is corrupt: app.asar has sha256 %s, expected %s", b.Id, hash, b.Sha256)
+	}
+
+	if b.UnpackedSha256 != "" {
+		if hash, err := HashPath(b.asarFile() + ".unpacked"); err != nil {
+			return err
+		} else if hash != b.UnpackedSha256 {
+			return fmt.Errorf("Backup %s is corrupt: app.asar.unpacked has sha256 %s, expected %s", b.Id, hash, b.UnpackedSha256)
+		}
+	}
+	return nil
+}
+
+// RestoreBackup puts the backed up app.asar back into di, removing Vencord and OpenAsar in the process,
+// then checks that the result is byte identical to the backup
+func (di *DiscordInstall) RestoreBackup(b *Backup) error {
+	Log.Info("Restoring backup", b.Id, "to", di.path+"...")
+
+	if err := b.Verify(); err != nil {
+		return err
+	}
+	if version := di.discordVersion(); version != b.Version {
+		return fmt.Errorf("Backup %s is of Discord %s, but %s is Discord %s", b.Id, b.Version, di.path, Ternary(version != "", version, "unknown"))
+	}
+
+	if err := PreparePatch(di); err != nil {
+		return err
+	}
+
+	appAsar := di.appAsar()
+	_appAsar := di.originalAsar()
+
+	plan := NewPlan("backup restore", di)
+	plan.Copy(b.asarFile(), appAsar+".restore")
+	if b.UnpackedSha256 != "" {
+		plan.Copy(b.asarFile()+".unpacked", appAsar+".unpacked.restore")
+	}
+
+	var leftovers []string
+	for _, file := range []string{appAsar, _appAsar} {
+		if !ExistsFile(file) {
+			continue
+		}
+		old := unusedPath(file + ".old")
+		plan.Rename(file, old)
+		leftovers = append(leftovers, old)
+		if b.UnpackedSha256 != "" && ExistsFile(file+".unpacked") {
+			oldUnpacked := unusedPath(file + ".unpacked.old")
+			plan.Rename(file+".unpacked", oldUnpacked)
+			leftovers = append(leftovers, oldUnpacked)
+		}
+	}
+
+	plan.Rename(appAsar+".restore", appAsar)
+	if b.UnpackedSha256 != "" {
+		plan.Rename(appAsar+".unpacked.restore", appAsar+".unpacked")
+	}
+	for _, file := range leftovers {
+		plan.Remove(file)
+	}
+
+	if err := plan.Execute(); err != nil {
+		return err
+	}
+
+	if hash, err := HashPath(appAsar); err != nil {
+		return err
+	} else if hash != b.Sha256 {
+		return fmt.Errorf("Restored %s has sha256 %s, expected %s", appAsar, hash, b.Sha256)
+	}
+	if b.UnpackedSha256 != "" {
+		if hash, err := HashPath(appAsar + ".unpacked"); err != nil {
+			return err
+		} else if hash != b.UnpackedSha256 {
+			return fmt.Errorf("Restored %s has sha256 %s, expected %s", appAsar+".unpacked", hash, b.UnpackedSha256)
+		}
+	}
+
+	Log.Info("Successfully restored", di.path, "to vanilla Discord", b.Version)
+	di.refreshState()
+	return nil
+}
+
+// PruneBackups deletes all but the newest keep backups of each install and returns the deleted ones
+func PruneBackups(keep int) ([]*Backup, error) {
+	perInstall := make(map[string]int)
+	var deleted []*Backup
+	for _, b := range ListBackups() {
+		perInstall[b.Install]++
+		if perInstall[b.Install] <= keep {
+			continue
+		}
+
+		Log.Info("Deleting backup", b.Id)
+		if err := os.RemoveAll(b.dir); err != nil {
+			return deleted, fmt.Errorf("Failed to delete backup %s: %w", b.Id, err)
+		}
+		deleted = append(deleted, b)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListBackups(t *testing.T) {
+	useTempBaseDir(t)
+	sha := strings.Repeat("ab", 32)
+	manifests := map[string]string{
+		"stable-1.0.9013-abababab": `{"id":"stable-1.0.9013-abababab","branch":"stable","sha256":"` + sha + `","created":"2023-05-01T00:00:00Z"}`,
+		"canary-0.0.160-abababab":  `{"id":"canary-0.0.160-abababab","branch":"canary","sha256":"` + sha + `","unpackedSha256":"` + sha + `","created":"2023-06-01T00:00:00Z"}`,
+		"corrupt":                  `{"id":`,
+		"short-sha":                `{"id":"short-sha","sha256":"abc","created":"2023-07-01T00:00:00Z"}`,
+		"no-sha":                   `{"id":"no-sha","created":"2023-07-01T00:00:00Z"}`,
+		"bad-unpacked-sha":         `{"id":"bad-unpacked-sha","sha256":"` + sha + `","unpackedSha256":"x","created":"2023-07-01T00:00:00Z"}`,
+		"bad-id":                   `{"id":"../stable","sha256":"` + sha + `","created":"2023-07-01T00:00:00Z"}`,
+	}
+	for dir, manifest := range manifests {
+		if err := os.MkdirAll(path.Join(backupsDir(), dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(backupsDir(), dir, "backup.json"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Unfinished backup without a manifest
+	if err := os.MkdirAll(path.Join(backupsDir(), "unfinished"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := ListBackups()
+	ids := SliceMap(backups, func(b *Backup) string { return b.Id })
+	if want := []string{"canary-0.0.160-abababab", "stable-1.0.9013-abababab"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("listed %v, want %v", ids, want)
+	}
+	for _, b := range backups {
+		if want := path.Join(backupsDir(), b.Id); b.dir != want {
+			t.Errorf("backup %s is in %s, want %s", b.Id, b.dir, want)
+		}
+		if s := b.String(); !strings.Contains(s, sha[:12]) {
+			t.Errorf("%q doesn't show the short hash", s)
+		}
+	}
+}
+
+func TestUnusedPath(t *testing.T) {
+	dir := t.TempDir()
+	p := path.Join(dir, "app.asar.old")
+	if got := unusedPath(p); got != p {
+		t.Errorf("unusedPath is %s, want %s", got, p)
+	}
+
+	for _, file := range []string{p, p + ".2"} {
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := unusedPath(p), p+".3"; got != want {
+		t.Errorf("unusedPath is %s, want %s", got, want)
+	}
+}
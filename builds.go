@@ -0,0 +1,257 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultKeepBuilds is how many builds are kept unless the user configured something else
+const DefaultKeepBuilds = 3
+
+// Build is a Vencord build that was installed at some point. Each build lives in its own folder in buildsDir()
+// with a build.json manifest, so switching back to it doesn't need to download it again
+type Build struct {
+	Id     string `json:"id"`
+	Source string `json:"source"`
+	Tag    string `json:"tag"`
+	Hash   string `json:"hash"`
+	// sha256 of every file, by name, as downloaded
+	Files     map[string]string `json:"files"`
+	Installed time.Time         `json:"installed"`
+
+	dir string
+}
+
+func buildsDir() string {
+	return path.Join(BaseDir, "builds")
+}
+
+func (b *Build) String() string {
+	return fmt.Sprintf("%s %s (%s) - %s", b.Tag, b.Hash, b.Source, b.Installed.Format("2006-01-02 15:04"))
+}
+
+// IsActive reports whether b is the build in FilesDir
+func (b *Build) IsActive() bool {
+	return GetState().ActiveBuild == b.Id
+}
+
+// IsPinned reports whether the version its source is pinned to is b. Pins of rolling tags like devbuild can
+// only be resolved with the kept build once the tag moved on
+func (b *Build) IsPinned() bool {
+	src, err := ParseSource(b.Source)
+	if err != nil {
+		return false
+	}
+	return b.matches(PinnedVersion(src))
+}
+
+// matches reports whether version is the id or commit hash (or a prefix of it) of b
+func (b *Build) matches(version string) bool {
+	return version != "" && (b.Id == version || (len(version) >= 7 && strings.HasPrefix(b.Hash, version)))
+}
+
+// KeepBuilds returns how many builds to keep
+func KeepBuilds() int {
+	if keep := GetState().KeepBuilds; keep > 0 {
+		return keep
+	}
+	return DefaultKeepBuilds
+}
+
+// ListBuilds returns all kept builds, most recently installed first
+func ListBuilds() []*Build {
+	dir := buildsDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			Log.Warn("Failed to read builds dir", dir+":", err)
+		}
+		return nil
+	}
+
+	var builds []*Build
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		manifest := path.Join(dir, entry.Name(), "build.json")
+		b, err := os.ReadFile(manifest)
+		if err != nil {
+			Log.Debug("Ignoring unfinished build", path.Join(dir, entry.Name())+":", err)
+			continue
+		}
+
+		var build Build
+		if err = json.Unmarshal(b, &build); err != nil {
+			Log.Warn("Ignoring corrupt build manifest", manifest+":", err)
+			continue
+		}
+		build.dir = path.Join(dir, entry.Name())
+		builds = append(builds, &build)
+	}
+
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Installed.After(builds[j].Installed)
+	})
+	return builds
+}
+
+// FindBuild returns the kept build with the given id, commit hash (or a prefix of it) or tag
+func FindBuild(version string) (*Build, error) {
+	var matches []*Build
+	for _, b := range ListBuilds() {
+		if b.Id == version {
+			return b, nil
+		}
+		if b.Tag == version || (len(version) >= 7 && strings.HasPrefix(b.Hash, version)) {
+			matches = append(matches, b)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, errors.New("No kept build matches " + version)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, errors.New(version + " matches several builds, pick one by id: " +
+			strings.Join(SliceMap(matches, func(b *Build) string { return b.Id }), ", "))
+	}
+}
+
+// PreviousBuild returns the build that was installed before the active one
+func PreviousBuild() (*Build, error) {
+	builds := ListBuilds()
+	for i, b := range builds {
+		if b.IsActive() {
+			if i+1 == len(builds) {
+				return nil, errors.New("No build older than " + b.Id + " is kept")
+			}
+			return builds[i+1], nil
+		}
+	}
+	if len(builds) == 0 {
+		return nil, errors.New("No builds are kept yet. Builds are kept when they are installed")
+	}
+	// The active build isn't kept, go back to the most recent one that is
+	return builds[0], nil
+}
+
+// keepBuild copies the build of src that was just installed to FilesDir into its own folder in buildsDir()
+func keepBuild(src *Source, tag, hash string, files map[string]string) (*Build, error) {
+	build := &Build{
+		Id:        unsafeIdChars.ReplaceAllString(tag+"-"+hash, "_"),
+		Source:    src.Id,
+		Tag:       tag,
+		Hash:      hash,
+		Files:     files,
+		Installed: time.Now(),
+	}
+	build.dir = path.Join(buildsDir(), build.Id)
+
+	// Installed again, start over in case the old copy is damaged
+	if err := os.RemoveAll(build.dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(buildsDir(), 0755); err != nil {
+		return nil, err
+	}
+	if err := CopyPath(FilesDir, build.dir); err != nil {
+		_ = os.RemoveAll(build.dir)
+		return nil, err
+	}
+
+	// The manifest goes last, builds without one are ignored so a half copied build never shows up
+	manifest, err := json.MarshalIndent(build, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(path.Join(build.dir, "build.json"), manifest, 0644); err != nil {
+		return nil, err
+	}
+	return build, FixOwnership(buildsDir())
+}
+
+// Verify checks the files of b against the hashes recorded when it was installed
+func (b *Build) Verify() error {
+	for name, expected := range b.Files {
+		if hash, err := HashPath(path.Join(b.dir, name)); err != nil {
+			return fmt.Errorf("Build %s is incomplete: %w", b.Id, err)
+		} else if hash != expected {
+			return fmt.Errorf("Build %s is corrupt: %s has sha256 %s, expected %s", b.Id, name, hash, expected)
+		}
+	}
+	return nil
+}
+
+// UseBuild makes the kept build b the one in FilesDir, without downloading anything
+func UseBuild(b *Build) error {
+	if IsDevInstall {
+		return errors.New("Not switching builds as this is a dev install, which uses the build in " + FilesDir + " as is")
+	}
+	Log.Info("Switching to Vencord build", b.Id+"...")
+
+	if err := b.Verify(); err != nil {
+		return err
+	}
+
+	staged := stagingDir()
+	if err := os.RemoveAll(staged); err != nil {
+		return err
+	}
+	if err := CopyPath(b.dir, staged); err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
+	_ = os.Remove(path.Join(staged, "build.json"))
+	if err := FixOwnership(staged); err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
+	if err := swapDist(staged); err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
+
+	InstalledHash = b.Hash
+	if err := UpdateState(func(s *InstallerState) {
+		s.Source = b.Source
+		s.DistHashes = b.Files
+		s.ActiveBuild = b.Id
+	}); err != nil {
+		Log.Warn("Failed to record that", b.Id, "is installed:", err)
+	}
+
+	Log.Info("Now using Vencord build", b.Id)
+	return nil
+}
+
+// PruneBuilds deletes all but the keep most recently installed builds and returns the deleted ones. The active
+// and pinned builds are always kept
+func PruneBuilds(keep int) ([]*Build, error) {
+	var deleted []*Build
+	for i, b := range ListBuilds() {
+		if i < keep || b.IsActive() || b.IsPinned() {
+			continue
+		}
+
+		Log.Info("Deleting Vencord build", b.Id)
+		if err := os.RemoveAll(b.dir); err != nil {
+			return deleted, fmt.Errorf("Failed to delete build %s: %w", b.Id, err)
+		}
+		deleted = append(deleted, b)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,96 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	path "path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// addBuild keeps a build of DefaultSource with the given hash, installed age days ago
+func addBuild(t *testing.T, hash string, age int) *Build {
+	t.Helper()
+	b := &Build{
+		Id:        "devbuild-" + hash,
+		Source:    DefaultSource.Id,
+		Tag:       "devbuild",
+		Hash:      hash,
+		Files:     map[string]string{},
+		Installed: time.Now().AddDate(0, 0, -age),
+	}
+	dir := path.Join(buildsDir(), b.Id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := json.Marshal(b)
+	if err := os.WriteFile(path.Join(dir, "build.json"), manifest, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestPruneBuilds(t *testing.T) {
+	hashes := []string{"aaaaaaa1", "bbbbbbb2", "ccccccc3", "ddddddd4"}
+
+	tests := []struct {
+		name   string
+		keep   int
+		active string
+		pinned string
+		// hashes of the builds left
+		want []string
+	}{
+		{"keep all", 4, "", "", hashes},
+		{"keep newest", 2, "", "", hashes[:2]},
+		{"keep active", 1, "ccccccc3", "", []string{"aaaaaaa1", "ccccccc3"}},
+		{"keep pinned by hash", 1, "", "ddddddd4", []string{"aaaaaaa1", "ddddddd4"}},
+		{"keep pinned by short hash", 1, "", "ddddddd", []string{"aaaaaaa1", "ddddddd4"}},
+		{"keep pinned by id", 1, "", "devbuild-bbbbbbb2", []string{"aaaaaaa1", "bbbbbbb2"}},
+		{"keep active and pinned", 1, "bbbbbbb2", "ddddddd4", []string{"aaaaaaa1", "bbbbbbb2", "ddddddd4"}},
+		{"too short to be a hash", 1, "", "dddd", hashes[:1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			for i, hash := range hashes {
+				addBuild(t, hash, i)
+			}
+			if err := UpdateState(func(s *InstallerState) {
+				if tt.active != "" {
+					s.ActiveBuild = "devbuild-" + tt.active
+				}
+				if tt.pinned != "" {
+					s.PinnedVersions = map[string]string{DefaultSource.Id: tt.pinned}
+				}
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := PruneBuilds(tt.keep); err != nil {
+				t.Fatal(err)
+			}
+			got := SliceMap(ListBuilds(), func(b *Build) string { return b.Hash })
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+
+			// The pin can still be resolved without the network
+			if tt.pinned != "" && len(tt.pinned) >= 7 {
+				if b := pinnedBuild(DefaultSource, tt.pinned); b == nil {
+					t.Errorf("pin %s doesn't resolve to a kept build anymore", tt.pinned)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+	"vencordinstaller/asar"
+)
+
+// checksumManifests are the names of release assets listing the sha256 of the other assets, like sha256sum
+// prints them
+var checksumManifests = []string{"checksums.txt", "sha256sums", "sha256sums.txt", "checksums.sha256"}
+
+var sha256Re = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// AllowUnverified installs assets the release doesn't list a sha256 for, only checking their content. Off unless
+// VENCORD_ALLOW_UNVERIFIED=1 or --allow-unverified is given, as anything could have been downloaded instead
+var AllowUnverified = os.Getenv("VENCORD_ALLOW_UNVERIFIED") == "1"
+
+// AssetVerifyError means a downloaded asset isn't what the release promised. The staged build is kept, so the
+// files can be inspected
+type AssetVerifyError struct {
+	Asset   string
+	Problem string
+	Staged  string
+}
+
+func (e *AssetVerifyError) Error() string {
+	return fmt.Sprintf("Refusing to install the download of %s: %s. The downloaded files were kept in %s", e.Asset, e.Problem, e.Staged)
+}
+
+// checksumManifest returns the asset of release listing the checksums of the others, if it has one
+func checksumManifest(release *GithubRelease) *GithubAsset {
+	for _, ass := range release.Assets {
+		if SliceContains(checksumManifests, strings.ToLower(ass.Name)) {
+			return &ass
+		}
+	}
+	return nil
+}
+
+// parseChecksums reads the lines of a sha256sum style manifest, "<sha256>  <name>", into sha256 by name
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		sum = strings.ToLower(sum)
+		// sha256sum marks binary mode with *
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		if !ok || !sha256Re.MatchString(sum) || name == "" {
+			return nil, errors.New("malformed line '" + line + "'")
+		}
+		sums[strings.TrimPrefix(name, "./")] = sum
+	}
+	return sums, scanner.Err()
+}
+
+// expectedDigests returns the sha256 each of assets should have, from the digests GitHub reports for assets and
+// the checksum manifest of release. Assets neither of them knows are missing from the result
+func expectedDigests(release *GithubRelease, assets []GithubAsset) (map[string]string, error) {
+	var manifest map[string]string
+	if ass := checksumManifest(release); ass != nil {
+		Log.Debug("Downloading checksums from", ass.Name)
+		res, err := http.Get(ass.DownloadURL)
+		if err == nil && res.StatusCode >= 300 {
+			_ = res.Body.Close()
+			err = errors.New(res.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to download the checksums in %s: %w", ass.Name, err)
+		}
+		manifest, err = parseChecksums(io.LimitReader(res.Body, 1024*1024))
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the checksums in %s: %w", ass.Name, err)
+		}
+	}
+
+	digests := make(map[string]string)
+	for _, ass := range assets {
+		// GitHub reports them like sha256:<hex>
+		reported, hasReported := strings.CutPrefix(strings.ToLower(ass.Digest), "sha256:")
+		listed, hasListed := manifest[ass.Name]
+		if hasReported && hasListed && reported != listed {
+			return nil, fmt.Errorf("GitHub reports sha256 %s for %s, but the checksum manifest lists %s", reported, ass.Name, listed)
+		}
+		switch {
+		case hasReported:
+			digests[ass.Name] = reported
+		case hasListed:
+			digests[ass.Name] = listed
+		}
+	}
+	return digests, nil
+}
+
+// validateAsset checks that a downloaded asset has the size the release reports and is the type of file its name
+// says: text that isn't an HTML or JSON page for js and css, an archive with a valid header for asar. That way a
+// captive portal's login page or an error page never ends up in the dist. Returns "" if it looks right
+func validateAsset(file string, ass GithubAsset) string {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err.Error()
+	}
+	if len(b) == 0 {
+		return "it is empty"
+	}
+	if ass.Size > 0 && int64(len(b)) != ass.Size {
+		return fmt.Sprintf("it has %d bytes, but the release says %d", len(b), ass.Size)
+	}
+
+	ext := strings.ToLower(ass.Name[strings.LastIndex(ass.Name, ".")+1:])
+	switch ext {
+	case "asar":
+		archive, err := asar.Open(file)
+		if err != nil {
+			return "it is not an asar archive: " + err.Error()
+		}
+		_ = archive.Close()
+		return ""
+	case "js", "css":
+	default:
+		return ""
+	}
+
+	if !utf8.Valid(b) {
+		return "it is not text, but " + ext + " has to be"
+	}
+	start := bytes.TrimSpace(bytes.TrimPrefix(b, []byte("\uFEFF")))
+	if json.Valid(start) && (bytes.HasPrefix(start, []byte("{")) || bytes.HasPrefix(start, []byte("["))) {
+		return "it is a JSON response instead of " + ext + ", maybe an error of the server"
+	}
+	if len(start) > 512 {
+		start = start[:512]
+	}
+	start = bytes.ToLower(start)
+	if bytes.HasPrefix(start, []byte("<")) || bytes.Contains(start, []byte("<!doctype html")) || bytes.Contains(start, []byte("<html")) {
+		return "it is an HTML page instead of " + ext + ", maybe a login page of the network or an error page"
+	}
+	return ""
+}
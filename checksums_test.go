@@ -0,0 +1,134 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"vencordinstaller/asar"
+)
+
+func TestParseChecksums(t *testing.T) {
+	a, b := strings.Repeat("a", 64), strings.Repeat("b", 64)
+
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", in: "", want: map[string]string{}},
+		{name: "sha256sum", in: a + "  patcher.js\n" + b + "  renderer.css\n", want: map[string]string{"patcher.js": a, "renderer.css": b}},
+		{name: "binary mode and dot slash", in: a + " *patcher.js\n" + b + "  ./renderer.css\n", want: map[string]string{"patcher.js": a, "renderer.css": b}},
+		{name: "upper case", in: strings.ToUpper(a) + "  patcher.js\n", want: map[string]string{"patcher.js": a}},
+		{name: "comments and blank lines", in: "# Vencord\n\n" + a + "  patcher.js\r\n", want: map[string]string{"patcher.js": a}},
+		{name: "no name", in: a + "\n", wantErr: true},
+		{name: "short sum", in: "abc  patcher.js\n", wantErr: true},
+		{name: "other hash", in: strings.Repeat("a", 40) + "  patcher.js\n", wantErr: true},
+		{name: "html", in: "<!doctype html>\n<html>\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChecksums(strings.NewReader(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checksums are %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpectedDigests(t *testing.T) {
+	a, b := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	assets := []GithubAsset{{Name: "patcher.js", Digest: "sha256:" + a}, {Name: "renderer.js"}, {Name: "preload.js"}}
+
+	tests := []struct {
+		name     string
+		manifest string
+		// served instead of the manifest if set
+		missing bool
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "reported only", want: map[string]string{"patcher.js": a}},
+		{name: "reported and listed", manifest: a + "  patcher.js\n" + b + "  renderer.js\n", want: map[string]string{"patcher.js": a, "renderer.js": b}},
+		{name: "reported and listed differently", manifest: b + "  patcher.js\n", wantErr: true},
+		{name: "malformed manifest", manifest: "<html>", wantErr: true},
+		{name: "manifest not found", missing: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := &GithubRelease{Assets: assets}
+			if tt.manifest != "" || tt.missing {
+				srv := serveAssets(t, Ternary(tt.missing, map[string]string{}, map[string]string{"sha256sums.txt": tt.manifest}))
+				release.Assets = append(release.Assets, GithubAsset{Name: "SHA256SUMS.txt", DownloadURL: srv.URL + "/sha256sums.txt"})
+			}
+
+			got, err := expectedDigests(release, assets)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("digests are %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAsset(t *testing.T) {
+	var archive bytes.Buffer
+	if err := asar.Write(&archive, "", []asar.File{{Name: "index.js", Data: []byte("1")}}, asar.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		size    int64
+		valid   bool
+	}{
+		{name: "patcher.js", content: "require('electron')", valid: true},
+		{name: "renderer.css", content: "\uFEFFbody { color: red }", valid: true},
+		{name: "patcher.js", content: "require('electron')", size: 19, valid: true},
+		{name: "patcher.js", content: "require('electron')", size: 20},
+		{name: "patcher.js", content: ""},
+		{name: "patcher.js", content: "\n<!DOCTYPE html><html><body>Log in to the network</body></html>"},
+		{name: "renderer.css", content: "<html>"},
+		{name: "patcher.js", content: `{"message":"Not Found"}`},
+		{name: "patcher.js", content: "\xff\xfe\x00"},
+		{name: "app.asar", content: archive.String(), valid: true},
+		{name: "app.asar", content: "<html>"},
+		{name: "README.md", content: "<html>", valid: true},
+	}
+
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), tt.name)
+		writeFile(t, file, tt.content)
+		if problem := validateAsset(file, GithubAsset{Name: tt.name, Size: tt.size}); (problem == "") != tt.valid {
+			t.Errorf("%s %q (size %d): problem %q, want valid %v", tt.name, tt.content, tt.size, problem, tt.valid)
+		}
+	}
+}
@@ -9,6 +9,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -16,13 +17,61 @@ import (
 	"github.com/manifoldco/promptui"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"vencordinstaller/buildinfo"
 )
 
 var discords []any
+var discordsFound = false
 var interactive = false
 
+// runningAction is what to do if Discord is running while patching it, one of RunningActions or ask
+var runningAction = "ask"
+
+// CliCommand is a subcommand like "asar list". Commands register themselves in cliCommands from an init func
+type CliCommand struct {
+	Usage       string
+	Description string
+	Run         func(args []string) error
+}
+
+var cliCommands = map[string]*CliCommand{}
+
+// findDiscords returns the Discord installs, searching for them the first time. Commands only call it if they
+// need them, so commands like asar don't wait for the search
+func findDiscords() []any {
+	if !discordsFound {
+		discords = FindDiscords()
+		discordsFound = true
+	}
+	return discords
+}
+
+// initRelease starts fetching the latest release of the source, unless that already happened. Commands that
+// install Vencord or compare the installed build against the latest one call it first
+func initRelease() {
+	if GithubDoneChan != nil {
+		return
+	}
+	InitGithubDownloader()
+	Log.Debug("Using source", SelectedSource.Name)
+}
+
+// hasBareVersionFlag reports whether args contain --version without a value, which prints the installer version.
+// With a value, like --version v1.2 or --version=abc1234, it pins the Vencord version to install
+func hasBareVersionFlag(args []string) bool {
+	for i, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if (arg == "-version" || arg == "--version") && (i+1 == len(args) || strings.HasPrefix(args[i+1], "-")) {
+			return true
+		}
+	}
+	return false
+}
+
 func isValidBranch(branch string) bool {
 	switch branch {
 	case "", "stable", "ptb", "canary", "auto":
@@ -38,22 +87,36 @@ func die(msg string) {
 }
 
 func main() {
-	InitGithubDownloader()
-	discords = FindDiscords()
-
 	// Used by log.go init func
 	flag.Bool("debug", false, "Enable debug info")
 
 	var helpFlag = flag.Bool("help", false, "View usage instructions")
-	var versionFlag = flag.Bool("version", false, "View the program version")
+	var versionFlag = flag.String("version", "", "View the program version. With a value, like --version <tag|hash>, install that Vencord release instead of the latest and stick to it when repairing. --version latest unpins it again")
 	var updateSelfFlag = flag.Bool("update-self", false, "Update me to the latest version")
 	var installFlag = flag.Bool("install", false, "Install Vencord")
 	var updateFlag = flag.Bool("repair", false, "Repair Vencord")
 	var uninstallFlag = flag.Bool("uninstall", false, "Uninstall Vencord")
 	var installOpenAsarFlag = flag.Bool("install-openasar", false, "Install OpenAsar")
 	var uninstallOpenAsarFlag = flag.Bool("uninstall-openasar", false, "Uninstall OpenAsar")
+	var autoFlag = flag.Bool("auto", false, "Do whatever the install needs based on its state: install, update or fix it, if possible")
 	var locationFlag = flag.String("location", "", "The location of the Discord install to modify")
-	var branchFlag = flag.String("branch", "", "The branch of Discord to modify [auto|stable|ptb|canary]")
+	var branchFlag = flag.String("branch", "", "The branch of Discord to modify [auto|stable|ptb|canary]. With --all, a comma separated filter")
+	var dryRunFlag = flag.Bool("dry-run", false, "Only print what would be done, without changing anything")
+	var jsonFlag = flag.Bool("json", false, "With --dry-run, print the plan as JSON")
+	var migrateFlag = flag.Bool("migrate", false, "Remove other client mods like BetterDiscord from the install before installing")
+	var allFlag = flag.Bool("all", false, "Modify all Discord installs. Use --branch and --type to filter them")
+	var typeFlag = flag.String("type", "", "With --all, only modify installs of these types [native|flatpak|system-electron], comma separated")
+	flag.StringVar(&runningAction, "if-running", "ask", "What to do if Discord is running [terminate|wait|abort|ignore]. By default, interactive runs ask and others ignore it")
+	flag.BoolVar(&RelaunchDiscord, "relaunch", false, "Start Discord again afterwards if it had to be closed. Linux only")
+	flag.StringVar(&FlatpakMode, "flatpak-mode", "", "How Flatpaks read Vencord [override|sandbox]. override grants access to its folder, sandbox copies it into the Flatpak's data. Defaults to the mode the install already uses")
+	flag.BoolVar(&AllowUnverified, "allow-unverified", AllowUnverified, "Install Vencord even if the release doesn't say which sha256 its files should have, only checking their content. Also enabled by VENCORD_ALLOW_UNVERIFIED=1")
+	var sourceFlag = flag.String("source", "", "Where to download Vencord from [vencordjp|vencord|owner/repo]. Defaults to the installed one")
+	flag.Usage = printUsage
+	// flag would complain that --version is missing its value
+	if hasBareVersionFlag(os.Args[1:]) {
+		printVersion()
+		return
+	}
 	flag.Parse()
 
 	if *helpFlag {
@@ -61,13 +124,54 @@ func main() {
 		return
 	}
 
-	if *versionFlag {
-		fmt.Println("Vencord Installer Cli", buildinfo.InstallerTag, "("+buildinfo.InstallerGitHash+")")
-		fmt.Println("Copyright (C) 2023 Vendicated and Vencord contributors")
-		fmt.Println("License GPLv3+: GNU GPL version 3 or later <https://gnu.org/licenses/gpl.html>.")
-		return
+	if runningAction != "ask" && !SliceContains(RunningActions, runningAction) {
+		die("The 'if-running' flag must be one of the following: [" + strings.Join(RunningActions, "|") + "]")
+	}
+
+	// Only Linux remembers how the Discord it closed was started
+	if RelaunchDiscord && runtime.GOOS != "linux" {
+		die("The 'relaunch' flag is only supported on Linux.")
 	}
 
+	if FlatpakMode != "" && FlatpakMode != FlatpakModeOverride && FlatpakMode != FlatpakModeSandbox {
+		die("The 'flatpak-mode' flag must be one of the following: [override|sandbox]")
+	}
+
+	if *sourceFlag != "" {
+		src, err := ParseSource(*sourceFlag)
+		if err != nil {
+			die(err.Error())
+		}
+		SelectedSource = src
+	}
+	versionSet := false
+	flag.Visit(func(f *flag.Flag) {
+		versionSet = versionSet || f.Name == "version"
+	})
+	if versionSet {
+		if *versionFlag == "" {
+			die("The 'version' flag needs a version, like --version <tag|hash>, or --version " + LatestVersion + " to unpin it")
+		}
+		if SelectedSource == nil {
+			SelectedSource = InstalledSource()
+		}
+		pin, err := ResolveVersion(SelectedSource, *versionFlag)
+		if err != nil {
+			die(err.Error())
+		}
+		// Only pinned once installing it worked
+		RequestVersion(SelectedSource, pin)
+		Log.Info(Ternary(pin == LatestVersion, "Installing the latest release of "+SelectedSource.Name, "Installing "+SelectedSource.Name+" "+pin))
+	}
+
+	// Commands set up only what they need
+	if flag.NArg() > 0 {
+		runCommand(flag.Args())
+	}
+
+	initRelease()
+	findDiscords()
+
 	if *updateSelfFlag {
 		if !<-SelfUpdateCheckDoneChan {
 			die("Can't update self because checking for updates failed")
@@ -83,20 +187,28 @@ func main() {
 		die("The 'location' and 'branch' flags are mutually exclusive.")
 	}
 
-	if !isValidBranch(*branchFlag) {
+	var allOpts allOptions
+	if *allFlag {
+		if *locationFlag != "" {
+			die("The 'location' and 'all' flags are mutually exclusive.")
+		}
+		allOpts.branches = parseFilter(*branchFlag, "branch", func(b string) bool { return b != "" && b != "auto" && isValidBranch(b) })
+		allOpts.types = parseFilter(*typeFlag, "type", func(t string) bool { return SliceContains(packageTypes, t) })
+	} else if *typeFlag != "" {
+		die("The 'type' flag only works together with 'all'.")
+	} else if !isValidBranch(*branchFlag) {
 		die("The 'branch' flag must be one of the following: [auto|stable|ptb|canary]")
 	}
 
-	if *installFlag || *updateFlag {
-		if !<-GithubDoneChan {
-			die("Not " + Ternary(*installFlag, "installing", "updating") + " as fetching release data failed")
-		}
+	install, uninstall, update, installOpenAsar, uninstallOpenAsar, auto := *installFlag, *uninstallFlag, *updateFlag, *installOpenAsarFlag, *uninstallOpenAsarFlag, *autoFlag
+	switches := []*bool{&install, &update, &uninstall, &installOpenAsar, &uninstallOpenAsar, &auto}
+	interactive = !SliceContainsFunc(switches, func(b *bool) bool { return *b })
+
+	if pendingPlans := PendingPlans(); len(pendingPlans) > 0 {
+		handlePendingPlans(pendingPlans)
 	}
 
-	install, uninstall, update, installOpenAsar, uninstallOpenAsar := *installFlag, *uninstallFlag, *updateFlag, *installOpenAsarFlag, *uninstallOpenAsarFlag
-	switches := []*bool{&install, &update, &uninstall, &installOpenAsar, &uninstallOpenAsar}
-	if !SliceContainsFunc(switches, func(b *bool) bool { return *b }) {
-		interactive = true
+	if interactive {
 
 		go func() {
 			<-SelfUpdateCheckDoneChan
@@ -112,6 +224,7 @@ func main() {
 			"Uninstall Vencord",
 			"Install OpenAsar",
 			"Uninstall OpenAsar",
+			"Fix automatically",
 			"View Help Menu",
 			"Update Vencord Installer",
 			"Quit",
@@ -139,33 +252,129 @@ func main() {
 		*switches[SliceIndex(choices, choice)] = true
 	}
 
+	if install || update || auto {
+		if !<-GithubDoneChan {
+			die("Not " + Ternary(update, "updating", "installing") + " as fetching release data failed")
+		}
+	}
+
+	if *allFlag {
+		// same order as switches
+		actions := []string{"install", "repair", "uninstall", "install-openasar", "uninstall-openasar", "auto"}
+		allOpts.action = actions[SliceIndexFunc(switches, func(b *bool) bool { return *b })]
+		allOpts.migrate, allOpts.dryRun, allOpts.json = *migrateFlag, *dryRunFlag, *jsonFlag
+		runAll(allOpts)
+	}
+
+	printPlan := func(plan *Plan, err error) error {
+		if err != nil {
+			return err
+		}
+		if *jsonFlag {
+			b, err := json.MarshalIndent(plan, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			return nil
+		}
+		plan.Describe(os.Stdout)
+		fmt.Println("Dry run, nothing was changed.")
+		return nil
+	}
+
 	var err error
 	var errSilent error
-	if install {
-		errSilent = PromptDiscord("patch", *locationFlag, *branchFlag).patch()
+
+	// migrate removes other client mods from di if allowed to. Returns false if nothing else should be done
+	migrate := func(di *DiscordInstall) bool {
+		if di.foreignMod == nil {
+			return true
+		}
+		if !*migrateFlag && !(interactive && promptMigrate(di)) {
+			Log.Error(di.ErrForeignMod().Error() + ". Rerun with --migrate to let the installer remove it")
+			exitFailure()
+		}
+		if *dryRunFlag {
+			err = printPlan(di.PlanRemoveForeignMod(di.foreignMod))
+			if err == nil && !*jsonFlag {
+				fmt.Println("Once " + di.foreignMod.Name + " is removed, Vencord would be installed. Run again with --dry-run after that to see how.")
+			}
+			return false
+		}
+		errSilent = di.RemoveForeignMod()
+		return errSilent == nil
+	}
+
+	if auto {
+		discord := PromptDiscord("fix", *locationFlag, *branchFlag)
+		Log.Info(discord.path, "is", discord.status.State.Label()+Ternary(len(discord.status.Reasons) > 0, ": "+strings.Join(discord.status.Reasons, ", "), ""))
+		action, autoErr := discord.AutoAction()
+		if autoErr != nil {
+			die(autoErr.Error())
+		}
+		if action == "" {
+			Log.Info("Nothing to do, Vencord is installed and up to date")
+			if !*dryRunFlag {
+				if err = SavePinnedVersions(); err != nil {
+					Log.Warn("Failed to remember the version that is installed:", err)
+					err = nil
+				}
+			}
+		} else if migrate(discord) {
+			if *dryRunFlag {
+				err = printPlan(discord.PlanPatch())
+			} else {
+				errSilent = discord.patch()
+			}
+		}
+	} else if install {
+		discord := PromptDiscord("patch", *locationFlag, *branchFlag)
+		if migrate(discord) {
+			if *dryRunFlag {
+				err = printPlan(discord.PlanPatch())
+			} else {
+				errSilent = discord.patch()
+			}
+		}
 	} else if uninstall {
-		errSilent = PromptDiscord("unpatch", *locationFlag, *branchFlag).unpatch()
+		discord := PromptDiscord("unpatch", *locationFlag, *branchFlag)
+		if *dryRunFlag {
+			err = printPlan(discord.PlanUnpatch())
+		} else {
+			errSilent = discord.unpatch()
+		}
 	} else if update {
-		Log.Info("Downloading latest Vencord files...")
-		err := installLatestBuilds()
-		Log.Info("Done!")
-		if err == nil {
-			errSilent = PromptDiscord("repair", *locationFlag, *branchFlag).patch()
+		discord := PromptDiscord("repair", *locationFlag, *branchFlag)
+		if migrate(discord) {
+			if *dryRunFlag {
+				err = printPlan(discord.PlanRepair())
+			} else {
+				errSilent = discord.repair()
+			}
 		}
 	} else if installOpenAsar {
 		discord := PromptDiscord("patch", *locationFlag, *branchFlag)
-		if !discord.IsOpenAsar() {
-			err = discord.InstallOpenAsar()
-		} else {
+		if discord.IsOpenAsar() {
 			die("OpenAsar already installed")
 		}
+		if migrate(discord) {
+			if *dryRunFlag {
+				err = printPlan(discord.PlanInstallOpenAsar())
+			} else {
+				err = discord.InstallOpenAsar()
+			}
+		}
 	} else if uninstallOpenAsar {
 		discord := PromptDiscord("patch", *locationFlag, *branchFlag)
-		if discord.IsOpenAsar() {
-			err = discord.UninstallOpenAsar()
-		} else {
+		if !discord.IsOpenAsar() {
 			die("OpenAsar not installed")
 		}
+		if *dryRunFlag {
+			err = printPlan(discord.PlanUninstallOpenAsar())
+		} else {
+			err = discord.UninstallOpenAsar()
+		}
 	}
 
 	if err != nil {
@@ -175,11 +384,55 @@ func main() {
 	if errSilent != nil {
 		exitFailure()
 	}
+	if *dryRunFlag && *jsonFlag {
+		// keep stdout valid JSON
+		exit(0)
+	}
 
 	exitSuccess()
 }
 
+func printVersion() {
+	fmt.Println("Vencord Installer Cli", buildinfo.InstallerTag, "("+buildinfo.InstallerGitHash+")")
+	fmt.Println("Copyright (C) 2023 Vendicated and Vencord contributors")
+	fmt.Println("License GPLv3+: GNU GPL version 3 or later <https://gnu.org/licenses/gpl.html>.")
+}
+
+func printUsage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "Usage: %s [flags]\n       %s <command> [args]\n\nFlags:\n", os.Args[0], os.Args[0])
+	flag.PrintDefaults()
+
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, _ = fmt.Fprintln(out, "\nCommands:")
+	for _, name := range names {
+		cmd := cliCommands[name]
+		_, _ = fmt.Fprintf(out, "  %s\n    \t%s\n", cmd.Usage, strings.ReplaceAll(cmd.Description, "\n", "\n    \t"))
+	}
+}
+
+func runCommand(args []string) {
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		die("Unknown command '" + args[0] + "'. Run with --help to see all commands")
+	}
+
+	if err := cmd.Run(args[1:]); err != nil {
+		Log.Error(err)
+		exitFailure()
+	}
+	exit(0)
+}
+
 func exit(status int) {
+	// os.Exit skips deferred calls, so this is where Discords closed for a change get started again. That includes
+	// failed changes, which were rolled back to the working install they had before
+	RelaunchStopped()
 	if runtime.GOOS == "windows" && IsDoubleClickRun() && interactive {
 		fmt.Print("Press Enter to exit")
 		var b byte
@@ -240,7 +493,7 @@ func PromptDiscord(action, dir, branch string) *DiscordInstall {
 	items := SliceMap(discords, func(d any) string {
 		install := d.(*DiscordInstall)
 		//goland:noinspection GoDeprecation
-		return fmt.Sprintf("%s - %s%s", strings.Title(install.branch), install.path, Ternary(install.isPatched, " [PATCHED]", ""))
+		return fmt.Sprintf("%s - %s%s%s", strings.Title(install.branch), install.path, aliasesTag(install), installTag(install))
 	})
 	items = append(items, "Custom Location")
 
@@ -268,10 +521,63 @@ func PromptDiscord(action, dir, branch string) *DiscordInstall {
 	}
 }
 
+func aliasesTag(di *DiscordInstall) string {
+	return Ternary(len(di.aliases) > 0, " (also "+strings.Join(di.aliases, ", ")+")", "")
+}
+
+func installTag(di *DiscordInstall) string {
+	if di.foreignMod != nil {
+		return " [" + strings.ToUpper(di.foreignMod.Name) + "]"
+	}
+	return Ternary(di.status.State == StateVanilla, "", " ["+strings.ToUpper(di.status.State.Label())+"]")
+}
+
+func promptMigrate(di *DiscordInstall) bool {
+	Log.Warn(di.path, "is modified by", di.foreignMod.String())
+	_, choice, err := (&promptui.Select{
+		Label: "Vencord can't be installed on top of " + di.foreignMod.Name + ". What would you like to do?",
+		Items: []string{"Remove " + di.foreignMod.Name + ", then continue", "Cancel"},
+	}).Run()
+	handlePromptError(err)
+	return choice != "Cancel"
+}
+
 func InstallLatestBuilds() error {
 	return installLatestBuilds()
 }
 
+// HandleRunningDiscord is called by PreparePatch when Discord is running. Returning an error aborts
+func HandleRunningDiscord(di *DiscordInstall, procs []DiscordProcess) error {
+	action := runningAction
+	if action == "ask" {
+		if !interactive {
+			Log.Warn("Discord is running (" + describeProcesses(procs) + "). Restart it after patching, or use --if-running")
+			return nil
+		}
+		choices := []string{"Close Discord, then continue", "Wait until Discord is closed", "Cancel"}
+		_, choice, err := (&promptui.Select{
+			Label: "Discord at " + di.path + " is running. What would you like to do?",
+			Items: choices,
+		}).Run()
+		handlePromptError(err)
+		action = RunningActions[SliceIndex(choices, choice)]
+	}
+
+	switch action {
+	case "terminate":
+		Log.Info("Closing Discord...")
+		return di.StopProcesses(procs)
+	case "wait":
+		Log.Info("Waiting for Discord to be closed...")
+		WaitForProcesses(procs)
+	case "abort":
+		return errors.New("Discord is running (" + describeProcesses(procs) + "). Close it and try again")
+	default:
+		Log.Warn("Discord is running. Restart it to load the changes")
+	}
+	return nil
+}
+
 func HandleScuffedInstall() {
 	fmt.Println("Hold On!")
 	fmt.Println("You have a broken Discord Install.")
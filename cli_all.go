@@ -0,0 +1,235 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var packageTypes = []string{"native", "flatpak", "system-electron"}
+
+type allOptions struct {
+	action   string // install, repair, uninstall, install-openasar, uninstall-openasar or auto
+	branches []string
+	types    []string
+	migrate  bool
+	dryRun   bool
+	json     bool
+}
+
+type allResult struct {
+	di      *DiscordInstall
+	plan    *Plan
+	skipped string
+	err     error
+}
+
+// parseFilter splits a comma separated flag value and checks every value with isValid
+func parseFilter(value, name string, isValid func(string) bool) []string {
+	if value == "" {
+		return nil
+	}
+	values := strings.Split(value, ",")
+	for _, v := range values {
+		if !isValid(v) {
+			die("Invalid " + name + " '" + v + "'")
+		}
+	}
+	return values
+}
+
+// matchingInstalls returns all detected installs that pass the branch and type filters
+func matchingInstalls(branches, types []string) []*DiscordInstall {
+	var installs []*DiscordInstall
+	for _, d := range discords {
+		di := d.(*DiscordInstall)
+		if len(branches) > 0 && !SliceContains(branches, di.branch) {
+			continue
+		}
+		if len(types) > 0 && !SliceContains(types, di.PackageType()) {
+			continue
+		}
+		installs = append(installs, di)
+	}
+	return installs
+}
+
+// runAll runs the action on every matching install, then prints a table of the results.
+// Exits with a non-zero status if any of them failed
+func runAll(opts allOptions) {
+	installs := matchingInstalls(opts.branches, opts.types)
+	if len(installs) == 0 {
+		die("No Discord install matches the given filters. Hint: snap is not supported")
+	}
+
+	patching := opts.action == "install" || opts.action == "repair" || opts.action == "auto"
+	// All installs share the dist, so only download it once
+	var distStep *Step
+	if patching && !IsDevInstall && (opts.action == "repair" || IsDistOutdated()) {
+		distStep = (&Plan{}).InstallDist()
+	}
+
+	if !opts.dryRun && distStep != nil {
+		Log.Info("Downloading", distStep.Description+"...")
+		if err := installLatestBuilds(); err != nil {
+			Log.Error("Failed to download Vencord, not touching any install:", err)
+			exitFailure()
+		}
+	}
+
+	results := SliceMap(installs, func(di *DiscordInstall) *allResult {
+		r := &allResult{di: di}
+		r.skipped, r.err = r.run(opts)
+		// a failed change was rolled back, so Discord is started again either way
+		if _, err := di.Relaunch(); err != nil {
+			Log.Warn("Failed to restart Discord at", di.path+":", err, "- Start it yourself to load the changes")
+		}
+		return r
+	})
+
+	if opts.dryRun {
+		printAllPlans(distStep, results, opts.json)
+	}
+	if !opts.json {
+		printAllResults(results, opts.dryRun)
+	}
+
+	if SliceContainsFunc(results, func(r *allResult) bool { return r.err != nil }) {
+		exitFailure()
+	}
+	if patching && !opts.dryRun {
+		// covers installs that were already up to date, the others did it when patching
+		if err := SavePinnedVersions(); err != nil {
+			Log.Warn("Failed to remember the version that is installed:", err)
+		}
+	}
+	if opts.json {
+		exit(0)
+	}
+	exitSuccess()
+}
+
+// run does (or plans, when dry running) the action on r.di. Returns why it was skipped, if it was
+func (r *allResult) run(opts allOptions) (string, error) {
+	di := r.di
+
+	switch opts.action {
+	case "auto":
+		action, err := di.AutoAction()
+		if err != nil {
+			return "", err
+		}
+		if action == "" {
+			return "up to date", nil
+		}
+		opts.action = "install"
+	case "uninstall":
+		if !di.IsPatched() && di.status.State != StateBroken {
+			return "not patched", nil
+		}
+	case "install-openasar":
+		if di.IsOpenAsar() {
+			return "OpenAsar already installed", nil
+		}
+	case "uninstall-openasar":
+		if !di.IsOpenAsar() {
+			return "OpenAsar not installed", nil
+		}
+	}
+
+	if di.foreignMod != nil && (opts.action == "install" || opts.action == "repair" || opts.action == "install-openasar") {
+		if !opts.migrate {
+			return "", errors.New("modified by " + di.foreignMod.String() + ", use --migrate to remove it")
+		}
+		if opts.dryRun {
+			r.plan, _ = di.PlanRemoveForeignMod(di.foreignMod)
+			return "", nil
+		}
+		if err := di.RemoveForeignMod(); err != nil {
+			return "", err
+		}
+	}
+
+	var err error
+	if opts.dryRun {
+		switch opts.action {
+		case "install", "repair":
+			r.plan, err = di.planPatch(opts.action, false)
+		case "uninstall":
+			r.plan, err = di.PlanUnpatch()
+		case "install-openasar":
+			r.plan, err = di.PlanInstallOpenAsar()
+		case "uninstall-openasar":
+			r.plan, err = di.PlanUninstallOpenAsar()
+		}
+		return "", err
+	}
+
+	switch opts.action {
+	case "install", "repair":
+		Log.Info("Patching " + di.path + "...")
+		err = di.executePatch(di.planPatch(opts.action, false))
+	case "uninstall":
+		err = di.unpatch()
+	case "install-openasar":
+		err = di.InstallOpenAsar()
+	case "uninstall-openasar":
+		err = di.UninstallOpenAsar()
+	}
+	return "", err
+}
+
+func printAllPlans(distStep *Step, results []*allResult, asJson bool) {
+	plans := SliceFilter(SliceMap(results, func(r *allResult) *Plan { return r.plan }), func(p *Plan) bool { return p != nil })
+
+	if asJson {
+		b, err := json.MarshalIndent(struct {
+			Dist  *Step   `json:"dist"`
+			Plans []*Plan `json:"plans"`
+		}{distStep, plans}, "", "  ")
+		if err != nil {
+			Log.Error(err)
+			exitFailure()
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	if distStep != nil {
+		fmt.Println("Once, before all installs:")
+		fmt.Println("  install-dist", distStep.Target)
+		fmt.Println("      ", distStep.Description)
+		fmt.Println()
+	}
+	for _, plan := range plans {
+		plan.Describe(os.Stdout)
+		fmt.Println()
+	}
+	fmt.Println("Dry run, nothing was changed.")
+}
+
+func printAllResults(results []*allResult, dryRun bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "\nBRANCH\tTYPE\tPATH\tRESULT")
+	for _, r := range results {
+		result := Ternary(dryRun, "planned", "ok")
+		if r.err != nil {
+			result = "FAILED: " + strings.ReplaceAll(r.err.Error(), "\n", " ")
+		} else if r.skipped != "" {
+			result = "skipped, " + r.skipped
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.di.branch, r.di.PackageType(), r.di.path, result)
+	}
+	_ = w.Flush()
+}
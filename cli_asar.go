@@ -0,0 +1,210 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	path "path/filepath"
+	"strings"
+	"text/tabwriter"
+	"vencordinstaller/asar"
+)
+
+var asarCommands = map[string]func(args []string) error{
+	"list":    asarList,
+	"extract": asarExtract,
+	"pack":    asarPack,
+	"diff":    asarDiff,
+}
+
+func init() {
+	cliCommands["asar"] = &CliCommand{
+		Usage: "asar <list|extract|pack|diff> [args]",
+		Description: "Inspect and build asar archives like app.asar, _app.asar or app.asar.backup\n" +
+			"asar list [-l] <archive>\n" +
+			"asar extract <archive> <dest dir>\n" +
+			"asar pack [--unpack glob] [--no-integrity] <dir> <archive>\n" +
+			"asar diff [--all] [--json] <archive> <archive>",
+		Run: func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("Missing asar command. Must be one of list, extract, pack, diff")
+			}
+			run, ok := asarCommands[args[0]]
+			if !ok {
+				return errors.New("Unknown asar command '" + args[0] + "'. Must be one of list, extract, pack, diff")
+			}
+			return run(args[1:])
+		},
+	}
+}
+
+func parseAsarFlags(fs *flag.FlagSet, args []string, argNames ...string) ([]string, error) {
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: asar %s [flags] %s\n", fs.Name(), strings.Join(argNames, " "))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != len(argNames) {
+		fs.Usage()
+		return nil, fmt.Errorf("asar %s expects %d arguments but got %d", fs.Name(), len(argNames), fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+func asarList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	long := fs.Bool("l", false, "Show size and flags of every entry")
+	args, err := parseAsarFlags(fs, args, "<archive>")
+	if err != nil {
+		return err
+	}
+
+	archive, err := asar.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	err = archive.Walk(func(name string, e *asar.Entry) error {
+		if !*long {
+			_, err := fmt.Fprintln(w, name)
+			return err
+		}
+
+		var kind, size, flags string
+		switch {
+		case e.IsDir():
+			kind = "dir"
+		case e.IsLink():
+			kind, flags = "link", "-> "+e.Link
+		default:
+			kind, size = "file", fmt.Sprint(e.Size)
+			flags = strings.TrimSpace(Ternary(e.Unpacked, "unpacked ", "") + Ternary(e.Executable, "executable", ""))
+		}
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", kind, size, name, flags)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func asarExtract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	args, err := parseAsarFlags(fs, args, "<archive>", "<dest dir>")
+	if err != nil {
+		return err
+	}
+
+	archive, err := asar.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	Log.Info("Extracting", args[0], "to", args[1]+"...")
+	return archive.Extract(args[1])
+}
+
+func asarPack(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ContinueOnError)
+	unpack := fs.String("unpack", "", "Store files whose name matches this glob (for example *.node) unpacked")
+	noIntegrity := fs.Bool("no-integrity", false, "Don't add integrity hashes to the header")
+	args, err := parseAsarFlags(fs, args, "<dir>", "<archive>")
+	if err != nil {
+		return err
+	}
+
+	if *unpack != "" {
+		if _, err = path.Match(*unpack, ""); err != nil {
+			return fmt.Errorf("Invalid --unpack glob: %w", err)
+		}
+	}
+
+	Log.Info("Packing", args[0], "into", args[1]+"...")
+	return asar.PackDir(args[0], args[1], asar.Options{
+		Integrity: !*noIntegrity,
+		Unpack: func(name string) bool {
+			if *unpack == "" {
+				return false
+			}
+			matched, _ := path.Match(*unpack, path.Base(name))
+			return matched
+		},
+	})
+}
+
+func asarDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Also show entries that are the same in both archives")
+	jsonOut := fs.Bool("json", false, "Print the result as json")
+	args, err := parseAsarFlags(fs, args, "<archive>", "<archive>")
+	if err != nil {
+		return err
+	}
+
+	var archives [2]*asar.Archive
+	for i, p := range args {
+		if archives[i], err = asar.Open(p); err != nil {
+			return err
+		}
+		//goland:noinspection GoDeferInLoop
+		defer archives[i].Close()
+	}
+
+	diff, err := asar.Diff(archives[0], archives[1])
+	if err != nil {
+		return err
+	}
+	if !*all {
+		diff = SliceFilter(diff, func(d asar.DiffEntry) bool {
+			return d.Status != asar.DiffSame
+		})
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+
+	if len(diff) == 0 {
+		fmt.Println("No differences")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "STATUS\tNAME\tOLD SIZE\tNEW SIZE\tOLD SHA256\tNEW SHA256")
+	for _, d := range diff {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.Status, d.Name,
+			Ternary(d.OldHash != "", fmt.Sprint(d.OldSize), "-"), Ternary(d.NewHash != "", fmt.Sprint(d.NewSize), "-"),
+			shortHash(d.OldHash), shortHash(d.NewHash),
+		)
+	}
+	return w.Flush()
+}
+
+func shortHash(hash string) string {
+	if hash == "" {
+		return "-"
+	}
+	if len(hash) > 12 && !strings.HasPrefix(hash, "link:") {
+		return hash[:12]
+	}
+	return hash
+}
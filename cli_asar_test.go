@@ -0,0 +1,272 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	path "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"vencordinstaller/asar"
+)
+
+// runAsar runs the asar command with args and returns what it printed
+func runAsar(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	err = cliCommands["asar"].Run(args)
+	_ = w.Close()
+	return <-out, err
+}
+
+// writeTree creates files, keyed by their slash separated name, in dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := path.Join(dir, path.FromSlash(name))
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAsarCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"src/index.js": "x", "not.asar": "<html>"})
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no command", nil},
+		{"unknown command", []string{"unzip"}},
+		{"list without archive", []string{"list"}},
+		{"list too many", []string{"list", "a", "b"}},
+		{"list missing archive", []string{"list", path.Join(dir, "missing.asar")}},
+		{"list not an archive", []string{"list", path.Join(dir, "not.asar")}},
+		{"extract one argument", []string{"extract", path.Join(dir, "not.asar")}},
+		{"pack invalid glob", []string{"pack", "--unpack", "[", path.Join(dir, "src"), path.Join(dir, "out.asar")}},
+		{"pack unknown flag", []string{"pack", "--fast", path.Join(dir, "src"), path.Join(dir, "out.asar")}},
+		{"diff one archive", []string{"diff", path.Join(dir, "not.asar")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runAsar(t, tt.args...); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestAsarPackListExtract(t *testing.T) {
+	files := map[string]string{
+		"package.json":          `{"name":"discord","main":"index.js"}`,
+		"index.js":              "require('./lib/main.js')",
+		"lib/main.js":           "module.exports = 1",
+		"node_modules/a/a.node": "native",
+	}
+
+	tests := []struct {
+		name   string
+		flags  []string
+		list   []string
+		listL  []string
+		unpack []string
+	}{
+		{
+			name: "plain",
+			list: []string{"index.js", "lib", "lib/main.js", "node_modules", "node_modules/a", "node_modules/a/a.node", "package.json"},
+			listL: []string{
+				"file  24  index.js",
+				"dir       lib",
+				"file  18  lib/main.js",
+				"dir       node_modules",
+				"dir       node_modules/a",
+				"file  6   node_modules/a/a.node",
+				"file  36  package.json",
+			},
+		},
+		{
+			name:   "unpack native modules",
+			flags:  []string{"--unpack", "*.node", "--no-integrity"},
+			list:   []string{"index.js", "lib", "lib/main.js", "node_modules", "node_modules/a", "node_modules/a/a.node", "package.json"},
+			listL:  []string{"file  6   node_modules/a/a.node  unpacked"},
+			unpack: []string{"node_modules/a/a.node"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := path.Join(dir, "src")
+			writeTree(t, src, files)
+			archive := path.Join(dir, "app.asar")
+
+			if _, err := runAsar(t, append(append([]string{"pack"}, tt.flags...), src, archive)...); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := runAsar(t, "list", archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Split(strings.TrimSpace(out), "\n"); !reflect.DeepEqual(got, tt.list) {
+				t.Errorf("list printed %q, want %q", got, tt.list)
+			}
+
+			out, err = runAsar(t, "list", "-l", archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := SliceMap(strings.Split(strings.TrimSpace(out), "\n"), func(s string) string { return strings.TrimRight(s, " ") })
+			for _, want := range tt.listL {
+				if !SliceContains(lines, want) {
+					t.Errorf("list -l didn't print %q:\n%s", want, out)
+				}
+			}
+
+			for _, name := range tt.unpack {
+				if _, err = os.Stat(path.Join(archive+".unpacked", path.FromSlash(name))); err != nil {
+					t.Errorf("%s wasn't unpacked: %v", name, err)
+				}
+			}
+
+			a, err := asar.Open(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := a.Lookup("index.js")
+			_ = a.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wantIntegrity := !SliceContains(tt.flags, "--no-integrity"); (e.Integrity != nil) != wantIntegrity {
+				t.Errorf("index.js has integrity %v, want %v", e.Integrity != nil, wantIntegrity)
+			}
+
+			dest := path.Join(dir, "out")
+			if _, err = runAsar(t, "extract", archive, dest); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range files {
+				got, err := os.ReadFile(path.Join(dest, path.FromSlash(name)))
+				if err != nil || string(got) != want {
+					t.Errorf("extracted %s is %q (%v), want %q", name, got, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAsarDiff(t *testing.T) {
+	original := map[string][]byte{
+		"package.json": []byte(`{"main":"index.js"}`),
+		"index.js":     []byte("require('./core')"),
+		"core.js":      []byte("core"),
+	}
+	stub := map[string][]byte{
+		"package.json": []byte(`{"main":"index.js"}`),
+		"index.js":     []byte(`require("/vencord/patcher.js")`),
+		"patcher.js":   []byte("vencord"),
+	}
+
+	tests := []struct {
+		name     string
+		old, new map[string][]byte
+		flags    []string
+		want     map[string]asar.DiffStatus
+	}{
+		{
+			name: "changes",
+			old:  original,
+			new:  stub,
+			want: map[string]asar.DiffStatus{"index.js": asar.DiffChanged, "core.js": asar.DiffRemoved, "patcher.js": asar.DiffAdded},
+		},
+		{
+			name:  "changes and same",
+			old:   original,
+			new:   stub,
+			flags: []string{"--all"},
+			want: map[string]asar.DiffStatus{
+				"index.js": asar.DiffChanged, "core.js": asar.DiffRemoved, "patcher.js": asar.DiffAdded, "package.json": asar.DiffSame,
+			},
+		},
+		{
+			name: "identical",
+			old:  original,
+			new:  original,
+			want: map[string]asar.DiffStatus{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			oldArchive, newArchive := path.Join(dir, "old.asar"), path.Join(dir, "new.asar")
+			if err := asar.PackMap(oldArchive, tt.old, asar.Options{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := asar.PackMap(newArchive, tt.new, asar.Options{}); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := runAsar(t, append(append([]string{"diff", "--json"}, tt.flags...), oldArchive, newArchive)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var diff []asar.DiffEntry
+			if err = json.Unmarshal([]byte(out), &diff); err != nil {
+				t.Fatalf("diff didn't print json: %v\n%s", err, out)
+			}
+			got := make(map[string]asar.DiffStatus)
+			for _, d := range diff {
+				got[d.Name] = d.Status
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diff is %v, want %v", got, tt.want)
+			}
+
+			out, err = runAsar(t, append(append([]string{"diff"}, tt.flags...), oldArchive, newArchive)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.want) == 0 {
+				if strings.TrimSpace(out) != "No differences" {
+					t.Errorf("diff printed %q, want No differences", out)
+				}
+				return
+			}
+			for name, status := range tt.want {
+				if !bytes.Contains([]byte(out), []byte(string(status)+"  ")) || !strings.Contains(out, name) {
+					t.Errorf("diff table is missing %s %s:\n%s", status, name, out)
+				}
+			}
+		})
+	}
+}
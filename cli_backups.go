@@ -0,0 +1,107 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	cliCommands["backups"] = &CliCommand{
+		Usage: "backups <list|restore|prune> [args]",
+		Description: "Manage the backups of original Discord files taken before patching\n" +
+			"backups list\n" +
+			"backups restore [--location dir] <id>\n" +
+			"backups prune [--keep n]",
+		Run: func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("Missing backups command. Must be one of list, restore, prune")
+			}
+			switch args[0] {
+			case "list":
+				return backupsList()
+			case "restore":
+				return backupsRestore(args[1:])
+			case "prune":
+				return backupsPrune(args[1:])
+			default:
+				return errors.New("Unknown backups command '" + args[0] + "'. Must be one of list, restore, prune")
+			}
+		},
+	}
+}
+
+func backupsList() error {
+	backups := ListBackups()
+	if len(backups) == 0 {
+		fmt.Println("No backups yet. A backup is made automatically the first time you patch a Discord version")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tBRANCH\tVERSION\tSHA256\tSIZE\tCREATED\tINSTALL")
+	for _, b := range backups {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", b.Id, b.Branch, b.Version, b.Sha256[:12], b.Size, b.Created.Format("2006-01-02 15:04"), b.Install)
+	}
+	return w.Flush()
+}
+
+func backupsRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	location := fs.String("location", "", "The Discord install to restore to. Defaults to the one the backup was taken from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("Usage: backups restore [--location dir] <id>")
+	}
+
+	backup := FindBackup(fs.Arg(0))
+	if backup == nil {
+		return errors.New("No backup with id " + fs.Arg(0) + ". Use 'backups list' to see all backups")
+	}
+
+	installPath := Ternary(*location != "", *location, backup.Install)
+	di := findInstall(installPath)
+	if di == nil {
+		return errors.New(installPath + " is not a valid Discord install")
+	}
+
+	return di.RestoreBackup(backup)
+}
+
+func backupsPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	keep := fs.Int("keep", 1, "How many backups to keep per install")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keep < 0 {
+		return errors.New("--keep must not be negative")
+	}
+
+	deleted, err := PruneBackups(*keep)
+	fmt.Println("Deleted", len(deleted), "backups")
+	return err
+}
+
+// findInstall returns the detected install at p, so it keeps the details found during discovery,
+// or parses p if it wasn't detected
+func findInstall(p string) *DiscordInstall {
+	for _, d := range findDiscords() {
+		if install := d.(*DiscordInstall); SliceContains(install.Paths(), p) {
+			return install
+		}
+	}
+	return ParseDiscord(p, "")
+}
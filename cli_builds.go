@@ -0,0 +1,102 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+func init() {
+	cliCommands["builds"] = &CliCommand{
+		Usage: "builds <list|use|rollback|keep> [args]",
+		Description: "Manage the Vencord builds kept after installing them, to switch between them without downloading\n" +
+			"builds list\n" +
+			"builds use <id|hash|tag>  switch to a kept build\n" +
+			"builds rollback  switch to the build installed before the current one\n" +
+			"builds keep [n]  show or set how many builds to keep, deleting the oldest ones",
+		Run: func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("Missing builds command. Must be one of list, use, rollback, keep")
+			}
+			switch args[0] {
+			case "list":
+				return buildsList()
+			case "use":
+				if len(args) != 2 {
+					return errors.New("Usage: builds use <id|hash|tag>")
+				}
+				b, err := FindBuild(args[1])
+				if err != nil {
+					return err
+				}
+				return buildsUse(b)
+			case "rollback":
+				b, err := PreviousBuild()
+				if err != nil {
+					return err
+				}
+				return buildsUse(b)
+			case "keep":
+				return buildsKeep(args[1:])
+			default:
+				return errors.New("Unknown builds command '" + args[0] + "'. Must be one of list, use, rollback, keep")
+			}
+		},
+	}
+}
+
+func buildsList() error {
+	builds := ListBuilds()
+	if len(builds) == 0 {
+		fmt.Println("No builds kept yet. Every build is kept once it is installed")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSOURCE\tTAG\tHASH\tINSTALLED\tACTIVE")
+	for _, b := range builds {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", b.Id, b.Source, b.Tag, b.Hash, b.Installed.Format("2006-01-02 15:04"), Ternary(b.IsActive(), "*", ""))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("Keeping the", KeepBuilds(), "most recent builds")
+	return nil
+}
+
+func buildsUse(b *Build) error {
+	if err := UseBuild(b); err != nil {
+		return err
+	}
+	return SyncSandboxDists(SliceMap(findDiscords(), func(d any) *DiscordInstall { return d.(*DiscordInstall) }))
+}
+
+func buildsKeep(args []string) error {
+	if len(args) == 0 {
+		fmt.Println("Keeping the", KeepBuilds(), "most recent builds")
+		return nil
+	}
+	keep, err := strconv.Atoi(args[0])
+	if err != nil || keep < 1 {
+		return errors.New("The number of builds to keep must be at least 1")
+	}
+
+	if err = UpdateState(func(s *InstallerState) {
+		s.KeepBuilds = keep
+	}); err != nil {
+		return err
+	}
+	deleted, err := PruneBuilds(keep)
+	fmt.Println("Keeping the", keep, "most recent builds. Deleted", len(deleted), "builds")
+	return err
+}
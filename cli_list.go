@@ -0,0 +1,75 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+type listedInstall struct {
+	Path       string      `json:"path"`
+	Branch     string      `json:"branch"`
+	Patched    bool        `json:"patched"`
+	ForeignMod *ForeignMod `json:"foreignMod,omitempty"`
+	Aliases    []string    `json:"aliases,omitempty"`
+	InstallStatus
+}
+
+func init() {
+	cliCommands["list"] = &CliCommand{
+		Usage:       "list [--json]",
+		Description: "List all Discord installs that were found, their state and why they are in it",
+		Run: func(args []string) error {
+			fs := flag.NewFlagSet("list", flag.ContinueOnError)
+			asJson := fs.Bool("json", false, "Print the installs as JSON")
+			if err := fs.Parse(args); err != nil {
+				return err
+			}
+
+			installs := SliceMap(findDiscords(), func(d any) listedInstall {
+				di := d.(*DiscordInstall)
+				return listedInstall{Path: di.path, Branch: di.branch, Patched: di.IsPatched(), ForeignMod: di.foreignMod, Aliases: di.aliases, InstallStatus: di.status}
+			})
+
+			if *asJson {
+				b, err := json.MarshalIndent(installs, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+				return nil
+			}
+
+			if len(installs) == 0 {
+				fmt.Println("No Discord install found. Hint: snap is not supported")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "BRANCH\tSTATE\tPATH")
+			for _, install := range installs {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", install.Branch, install.State.Label(), install.Path)
+				for _, alias := range install.Aliases {
+					_, _ = fmt.Fprintf(w, "\t\t  also at %s\n", alias)
+				}
+				if install.FlatpakMode != "" {
+					_, _ = fmt.Fprintf(w, "\t\t  flatpak mode: %s\n", install.FlatpakMode)
+				}
+				for _, reason := range install.Reasons {
+					_, _ = fmt.Fprintf(w, "\t\t  %s\n", reason)
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
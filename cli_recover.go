@@ -0,0 +1,97 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/manifoldco/promptui"
+)
+
+func init() {
+	cliCommands["recover"] = &CliCommand{
+		Usage:       "recover [--forward|--back]",
+		Description: "Finish or undo operations that were interrupted, for example by a crash or power loss",
+		Run:         runRecover,
+	}
+}
+
+func runRecover(args []string) error {
+	fs := flag.NewFlagSet("recover", flag.ContinueOnError)
+	forward := fs.Bool("forward", false, "Finish all interrupted operations without asking")
+	back := fs.Bool("back", false, "Undo all interrupted operations without asking")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *forward && *back {
+		return errors.New("The 'forward' and 'back' flags are mutually exclusive.")
+	}
+
+	plans := PendingPlans()
+	if len(plans) == 0 {
+		fmt.Println("Nothing to recover")
+		return nil
+	}
+
+	failed := false
+	for _, p := range plans {
+		var err error
+		if *forward || *back {
+			err = p.Recover(*forward)
+		} else {
+			err = promptRecoverPlan(p)
+		}
+		if err != nil {
+			Log.Error(err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return errors.New("Failed to recover some operations")
+	}
+	return nil
+}
+
+func promptRecoverPlan(p *Plan) error {
+	choices := []string{"Finish it", "Undo it", "Skip for now"}
+	_, choice, err := (&promptui.Select{
+		Label: "Interrupted " + p.String() + ". What would you like to do?",
+		Items: choices,
+	}).Run()
+	handlePromptError(err)
+
+	switch choice {
+	case "Finish it":
+		return p.Recover(true)
+	case "Undo it":
+		return p.Recover(false)
+	default:
+		return nil
+	}
+}
+
+// handlePendingPlans is called on startup. Interactive runs are asked what to do, everything else only gets a warning
+func handlePendingPlans(plans []*Plan) {
+	if !interactive {
+		for _, p := range plans {
+			Log.Warn("Found interrupted", p.String())
+		}
+		Log.Warn("Run with the 'recover' command to finish or undo them")
+		return
+	}
+
+	for _, p := range plans {
+		if err := promptRecoverPlan(p); err != nil {
+			Log.Error(err)
+		}
+	}
+	discords = FindDiscords()
+}
@@ -0,0 +1,55 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	cliCommands["releases"] = &CliCommand{
+		Usage: "releases",
+		Description: "List the releases of the source, use --source to pick another one. Install one with --version <version>\n" +
+			"Marks the installed release with * and the pinned one with pinned",
+		Run: func(args []string) error {
+			initRelease()
+			releases, err := ListReleases(SelectedSource)
+			if err != nil {
+				return err
+			}
+			if len(releases) == 0 {
+				fmt.Println(SelectedSource.Name, "has no releases")
+				return nil
+			}
+
+			pin := PinnedVersion(SelectedSource)
+			installed := InstalledSource().Id == SelectedSource.Id
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "TAG\tNAME\tVERSION\tPUBLISHED\tASSETS\t")
+			for _, r := range releases {
+				mark := Ternary(installed && r.Hash() == InstalledHash, "*", "")
+				if pin != "" && r.Matches(pin) {
+					mark = strings.TrimSpace(mark + " pinned")
+				}
+				assets := SliceMap(r.Assets, func(ass GithubAsset) string { return ass.Name })
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.TagName, r.Name, r.Version(), r.PublishedAt.Format("2006-01-02 15:04"), strings.Join(assets, ", "), mark)
+			}
+			if err = w.Flush(); err != nil {
+				return err
+			}
+			if pin != "" {
+				fmt.Println(SelectedSource.Name, "is pinned to", pin+". Unpin it with --version "+LatestVersion)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,135 @@
+//go:build cli && linux
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	cliCommands["store"] = &CliCommand{
+		Usage: "store <snippet|copy> [args]",
+		Description: "Install Vencord for Discord from the read-only Nix or Guix store\n" +
+			"store snippet [--location dir]  print Nix config that installs Discord with Vencord\n" +
+			"store copy [--location dir] [--to dir] [--dry-run]  copy Discord somewhere writable and patch the copy",
+		Run: func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("Missing store command. Must be one of snippet, copy")
+			}
+			switch args[0] {
+			case "snippet":
+				return storeSnippet(args[1:])
+			case "copy":
+				return storeCopy(args[1:])
+			default:
+				return errors.New("Unknown store command '" + args[0] + "'. Must be one of snippet, copy")
+			}
+		},
+	}
+}
+
+// storeInstall returns the store install at location, or the only detected one if location is empty
+func storeInstall(location string) (*DiscordInstall, error) {
+	if location != "" {
+		di := findInstall(location)
+		if di == nil {
+			return nil, errors.New(location + " is not a valid Discord install")
+		}
+		if di.store == "" {
+			return nil, errors.New(location + " is not in a read-only store, patch it directly")
+		}
+		return di, nil
+	}
+
+	var installs []*DiscordInstall
+	for _, d := range findDiscords() {
+		if di := d.(*DiscordInstall); di.store != "" {
+			installs = append(installs, di)
+		}
+	}
+	switch len(installs) {
+	case 0:
+		return nil, errors.New("No Discord install in the Nix or Guix store found")
+	case 1:
+		return installs[0], nil
+	default:
+		return nil, errors.New("Found several Discord installs in the store, pick one with --location:\n" +
+			strings.Join(SliceMap(installs, func(di *DiscordInstall) string { return di.path }), "\n"))
+	}
+}
+
+func storeSnippet(args []string) error {
+	fs := flag.NewFlagSet("snippet", flag.ContinueOnError)
+	location := fs.String("location", "", "The store install to print the snippet for")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	di, err := storeInstall(*location)
+	if err != nil {
+		return err
+	}
+	snippet, err := di.StoreSnippet()
+	if err != nil {
+		return err
+	}
+	fmt.Println(snippet)
+	return nil
+}
+
+func storeCopy(args []string) error {
+	fs := flag.NewFlagSet("copy", flag.ContinueOnError)
+	location := fs.String("location", "", "The store install to copy")
+	to := fs.String("to", "", "Where to copy it to. Defaults to ~/.local/share/vencord-discord[-branch]")
+	dryRun := fs.Bool("dry-run", false, "Only print what would be done, without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	initRelease()
+
+	di, err := storeInstall(*location)
+	if err != nil {
+		return err
+	}
+	dir := Ternary(*to != "", expandHome(*to), di.StoreCopyDir())
+
+	plan, err := di.PlanStoreCopy(dir)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		plan.Describe(os.Stdout)
+		fmt.Println("Dry run, nothing was changed. Afterwards, Vencord would be installed into the copy")
+		return nil
+	}
+
+	Log.Info("Copying", di.path, "to", dir+"...")
+	if err = plan.Execute(); err != nil {
+		return err
+	}
+	for _, step := range plan.Steps {
+		if err = FixOwnership(step.Target); err != nil {
+			return err
+		}
+	}
+	Log.Info("Copied", di.path, "to", dir+". It was added to your app menu")
+
+	copied := ParseDiscord(dir, di.branch)
+	if copied == nil {
+		return errors.New(dir + " is not a valid Discord install")
+	}
+	if !<-GithubDoneChan {
+		return errors.New("Not installing Vencord into the copy as fetching release data failed")
+	}
+	return copied.patch()
+}
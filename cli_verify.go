@@ -0,0 +1,87 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	cliCommands["verify"] = &CliCommand{
+		Usage: "verify [--location dir] [--json]",
+		Description: "Check that patched installs are complete and working. Checks all patched installs unless --location is given\n" +
+			"Exits with 0 if all checks passed, 1 if any failed and 2 if there was nothing to verify",
+		Run: runVerify,
+	}
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	location := fs.String("location", "", "The Discord install to verify")
+	asJson := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		exit(2)
+	}
+
+	var installs []*DiscordInstall
+	if *location != "" {
+		di := findInstall(*location)
+		if di == nil {
+			Log.Error(*location, "is not a valid Discord install")
+			exit(2)
+		}
+		installs = append(installs, di)
+	} else {
+		for _, d := range findDiscords() {
+			if di := d.(*DiscordInstall); di.WasPatched() {
+				installs = append(installs, di)
+			}
+		}
+		if len(installs) == 0 {
+			Log.Error("No patched Discord install found")
+			exit(2)
+		}
+	}
+
+	reports := SliceMap(installs, (*DiscordInstall).Verify)
+
+	if *asJson {
+		b, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for i, r := range reports {
+			if i > 0 {
+				_, _ = fmt.Fprintln(w)
+			}
+			_, _ = fmt.Fprintln(w, r.Install+":")
+			for _, c := range r.Checks {
+				_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\n", strings.ToUpper(string(c.Status)), c.Name, c.Detail)
+			}
+		}
+		_ = w.Flush()
+	}
+
+	if SliceContainsFunc(reports, func(r *VerifyReport) bool { return !r.Ok }) {
+		if *asJson {
+			exit(1)
+		}
+		return errors.New("Some checks failed")
+	}
+	return nil
+}
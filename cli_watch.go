@@ -0,0 +1,82 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func init() {
+	cliCommands["watch"] = &CliCommand{
+		Usage: "watch [--location dir] [--settle duration] [--retries n] [--max-backoff duration]",
+		Description: "Keep running and patch Discord again whenever it updates itself and replaces the patch. Linux only\n" +
+			"Watches all patched installs unless --location is given",
+		Run: runWatch,
+	}
+}
+
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	location := fs.String("location", "", "The Discord install to watch")
+	settle := fs.Duration("settle", 10*time.Second, "How long to wait for Discord to finish writing files before patching")
+	retries := fs.Int("retries", 5, "How often to retry a failed re-patch")
+	maxBackoff := fs.Duration("max-backoff", 5*time.Minute, "Maximum delay between retries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *retries < 0 {
+		return errors.New("--retries must not be negative")
+	}
+	initRelease()
+
+	var installs []*DiscordInstall
+	if *location != "" {
+		di := findInstall(*location)
+		if di == nil {
+			return errors.New(*location + " is not a valid Discord install")
+		}
+		if !di.WasPatched() {
+			return errors.New(*location + " is not patched. Install Vencord first")
+		}
+		installs = append(installs, di)
+	} else {
+		for _, d := range findDiscords() {
+			if di := d.(*DiscordInstall); di.WasPatched() {
+				installs = append(installs, di)
+			}
+		}
+		if len(installs) == 0 {
+			return errors.New("No patched Discord install found. Install Vencord first")
+		}
+	}
+
+	if !<-GithubDoneChan {
+		return errors.New("Not watching as fetching release data failed")
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		Log.Info("Received", sig.String()+", stopping")
+		close(stop)
+	}()
+
+	return Watch(installs, WatchOptions{
+		Settle:     *settle,
+		Retries:    *retries,
+		MaxBackoff: *maxBackoff,
+	}, stop)
+}
@@ -0,0 +1,315 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// SearchDirsEnv is a colon separated list of extra directories to look for Discord installs in,
+// like the ones in DiscordDirs. They may also be installs themselves
+const SearchDirsEnv = "VENCORD_DISCORD_DIRS"
+
+// searchDirsFile lists more extra directories, one per line. Lines starting with # are comments
+func searchDirsFile() string {
+	return path.Join(BaseDir, "search-dirs")
+}
+
+// expandHome replaces a leading ~ with the home of the actual user
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		return path.Join(Home, p[1:])
+	}
+	return p
+}
+
+// extraSearchDirs returns the search dirs added through SearchDirsEnv and searchDirsFile
+func extraSearchDirs() []string {
+	var dirs []string
+	for _, dir := range strings.Split(os.Getenv(SearchDirsEnv), ":") {
+		if dir != "" {
+			dirs = append(dirs, expandHome(dir))
+		}
+	}
+
+	f, err := os.Open(searchDirsFile())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			Log.Warn("Failed to read", searchDirsFile()+":", err)
+		}
+		return dirs
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			dirs = append(dirs, expandHome(line))
+		}
+	}
+	return dirs
+}
+
+// isInstallRoot reports whether dir looks like the base folder of a Discord install with a resources folder
+func isInstallRoot(dir string) bool {
+	resources := path.Join(dir, "resources")
+	return ExistsFile(path.Join(resources, "app.asar")) || ExistsFile(path.Join(resources, "_app.asar"))
+}
+
+// installRootOf walks up from a file of a Discord install, like its binary, to the base folder of the install
+func installRootOf(file string) string {
+	dir := path.Dir(file)
+	for i := 0; i < 3 && dir != "/"; i++ {
+		if isInstallRoot(dir) {
+			return dir
+		}
+		dir = path.Dir(dir)
+	}
+	return ""
+}
+
+// launcherNames are the names Discord's launchers have in bin folders, including the profiles of Nix and Guix
+var launcherNames = []string{
+	"discord",
+	"discordptb",
+	"discordcanary",
+	"discord-ptb",
+	"discord-canary",
+	"discord-development",
+	"Discord",
+	"DiscordPTB",
+	"DiscordCanary",
+}
+
+// launcherPathRe matches the absolute paths in a launcher script
+var launcherPathRe = regexp.MustCompile(`/[^\s"'$;:=()|&<>]+`)
+
+// readScript returns the start of file if it is a script. Binaries return nil
+func readScript(file string) []byte {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(io.LimitReader(f, 64*1024))
+	if err != nil || !bytes.HasPrefix(b, []byte("#!")) {
+		return nil
+	}
+	return b
+}
+
+// resolveLauncher follows symlinks and the paths in launcher scripts, like the wrappers distro packages and
+// makeWrapper generate, from file to the Discord install it starts: the base folder of a native install, or the
+// asar a system Electron runs, wherever it lives. Returns "" if it doesn't lead to one
+func resolveLauncher(file string, depth int) string {
+	resolved, err := path.EvalSymlinks(file)
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case info.IsDir() && isInstallRoot(resolved):
+		return resolved
+	case info.IsDir() && ExistsFile(path.Join(resolved, "app.asar")):
+		return path.Join(resolved, "app.asar")
+	case info.IsDir():
+		return ""
+	case strings.HasSuffix(resolved, ".asar"):
+		// resources/app.asar of a native install, run by a system Electron
+		if root := installRootOf(resolved); root != "" && path.Base(path.Dir(resolved)) == "resources" {
+			return root
+		}
+		return resolved
+	}
+	if root := installRootOf(resolved); root != "" {
+		return root
+	}
+	if depth == 0 {
+		return ""
+	}
+
+	for _, m := range launcherPathRe.FindAll(readScript(resolved), -1) {
+		if install := resolveLauncher(string(m), depth-1); install != "" {
+			Log.Debug(file, "launches", install)
+			return install
+		}
+	}
+	return ""
+}
+
+// launcherInstalls returns the installs the Discord launchers in the usual bin folders start
+func launcherInstalls() []installCandidate {
+	var candidates []installCandidate
+	for _, bin := range []string{"/usr/bin", "/usr/local/bin", "/bin", path.Join(Home, ".local/bin")} {
+		for _, name := range launcherNames {
+			if install := resolveLauncher(path.Join(bin, name), 3); install != "" {
+				candidates = append(candidates, installCandidate{install, GetBranch(name)})
+			}
+		}
+	}
+	return candidates
+}
+
+type desktopEntry struct {
+	file string
+	name string
+	exec string
+	path string
+}
+
+// desktopEntryDirs returns the applications folders of all XDG data dirs, most important first
+func desktopEntryDirs() []string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" || os.Getenv("SUDO_USER") != "" {
+		dataHome = path.Join(Home, ".local/share")
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	dirs := []string{path.Join(dataHome, "applications")}
+	for _, dir := range strings.Split(dataDirs, ":") {
+		if dir != "" {
+			dirs = append(dirs, path.Join(dir, "applications"))
+		}
+	}
+	return dirs
+}
+
+// parseDesktopEntry reads the keys we care about from the [Desktop Entry] group of a .desktop file
+func parseDesktopEntry(file string) (*desktopEntry, error) {
+	groups, err := readKeyFile(file)
+	if err != nil {
+		return nil, err
+	}
+	keys := groups["Desktop Entry"]
+	return &desktopEntry{file: file, name: keys["Name"], exec: keys["Exec"], path: keys["Path"]}, nil
+}
+
+// splitExec splits an Exec= value into its arguments, following the quoting rules of the desktop entry spec
+func splitExec(s string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted, escaped := false, false, false
+	for _, c := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// program returns the absolute path of the program e runs, skipping env and its variables
+func (e *desktopEntry) program() string {
+	args := splitExec(e.exec)
+	if len(args) > 0 && path.Base(args[0]) == "env" {
+		args = args[1:]
+		for len(args) > 0 && (strings.Contains(args[0], "=") || strings.HasPrefix(args[0], "-")) {
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return ""
+	}
+
+	program := expandHome(args[0])
+	if !path.IsAbs(program) {
+		p, err := exec.LookPath(program)
+		if err != nil {
+			return ""
+		}
+		program = p
+	}
+	return program
+}
+
+type installCandidate struct {
+	dir    string
+	branch string
+}
+
+// desktopEntryInstalls returns the installs launched by Discord's .desktop files
+func desktopEntryInstalls() []installCandidate {
+	var candidates []installCandidate
+	for _, dir := range desktopEntryDirs() {
+		files, _ := path.Glob(path.Join(dir, "*.desktop"))
+		for _, file := range files {
+			e, err := parseDesktopEntry(file)
+			if err != nil {
+				Log.Debug("Failed to read", file+":", err)
+				continue
+			}
+			if !strings.Contains(strings.ToLower(path.Base(file)+" "+e.name+" "+e.exec), "discord") {
+				continue
+			}
+
+			branch := GetBranch(strings.TrimSuffix(path.Base(file), ".desktop"))
+			if branch == "stable" {
+				branch = GetBranch(e.name)
+			}
+
+			for _, root := range []string{Ternary(isInstallRoot(expandHome(e.path)), expandHome(e.path), ""), resolveLauncher(e.program(), 3)} {
+				if root != "" {
+					Log.Debug(file, "launches the Discord install at", root)
+					candidates = append(candidates, installCandidate{root, branch})
+				}
+			}
+		}
+	}
+	return candidates
+}
+
+// installIdentity identifies an install no matter through which path it was found
+type installIdentity struct {
+	dev uint64
+	ino uint64
+}
+
+// identityOf returns the device and inode of the folder containing app.asar of di
+func identityOf(di *DiscordInstall) (installIdentity, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(di.asarDir(), &st); err != nil {
+		Log.Debug("Failed to stat", di.asarDir()+":", err)
+		return installIdentity{}, false
+	}
+	return installIdentity{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
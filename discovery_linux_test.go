@@ -0,0 +1,141 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"testing"
+	"vencordinstaller/asar"
+)
+
+// writeExecutable creates the file p, making its folder if needed
+func writeExecutable(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveLauncher(t *testing.T) {
+	dir := t.TempDir()
+	native := path.Join(dir, "opt", "discord")
+	writeExecutable(t, path.Join(native, "resources", "app.asar"), "asar")
+	writeExecutable(t, path.Join(native, "Discord"), "\x7fELF")
+	electronAsar := path.Join(dir, "usr", "lib", "discord", "discord.asar")
+	writeExecutable(t, electronAsar, "asar")
+	electronDir := path.Join(dir, "usr", "lib", "discord-canary")
+	writeExecutable(t, path.Join(electronDir, "app.asar"), "asar")
+
+	bin := path.Join(dir, "bin")
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path.Join(native, "Discord"), path.Join(bin, "discord")); err != nil {
+		t.Fatal(err)
+	}
+	writeExecutable(t, path.Join(bin, "discord-electron"), "#!/bin/sh\nexec electron28 "+electronAsar+" \"$@\"\n")
+	writeExecutable(t, path.Join(bin, "discord-wrapped"), "#!/bin/sh\nexport NIXOS_OZONE_WL=1\nexec -a \"$0\" \""+path.Join(bin, "discord-electron")+"\" \"$@\"\n")
+	writeExecutable(t, path.Join(bin, "discord-canary"), "#!/bin/sh\nexec electron --app="+electronDir+" \"$@\"\n")
+	writeExecutable(t, path.Join(bin, "discord-resources"), "#!/bin/sh\nexec electron "+path.Join(native, "resources", "app.asar")+"\n")
+	writeExecutable(t, path.Join(bin, "other"), "#!/bin/sh\nexec /usr/bin/true\n")
+	writeExecutable(t, path.Join(bin, "binary"), "\x7fELF "+electronAsar)
+
+	tests := []struct {
+		name  string
+		depth int
+		want  string
+	}{
+		{"discord", 3, native},
+		{"discord-electron", 3, electronAsar},
+		{"discord-wrapped", 3, electronAsar},
+		{"discord-wrapped", 2, electronAsar},
+		{"discord-wrapped", 1, ""},
+		{"discord-canary", 3, path.Join(electronDir, "app.asar")},
+		{"discord-resources", 3, native},
+		{"other", 3, ""},
+		{"binary", 3, ""},
+		{"missing", 3, ""},
+	}
+
+	for _, tt := range tests {
+		if got := resolveLauncher(path.Join(bin, tt.name), tt.depth); got != tt.want {
+			t.Errorf("resolveLauncher(%s, %d) is %q, want %q", tt.name, tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestSystemElectronAsar(t *testing.T) {
+	stub, err := BuildAppAsar(Patcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vanilla := map[string][]byte{"package.json": []byte(`{"name":"discord","main":"app_bootstrap/index.js"}`), "app_bootstrap/index.js": nil}
+
+	tests := []struct {
+		name  string
+		asars []string
+		stubs []string
+		// the asar Discord runs, and where patching moves the original to. No original if it isn't an install
+		want, original string
+	}{
+		{name: "app.asar", asars: []string{"app.asar"}, want: "app.asar", original: "_app.asar"},
+		{name: "patched app.asar", asars: []string{"_app.asar"}, stubs: []string{"app.asar"}, want: "app.asar", original: "_app.asar"},
+		{name: "renamed", asars: []string{"discord.asar"}, want: "discord.asar", original: "app.asar"},
+		{name: "renamed, patched", asars: []string{"app.asar"}, stubs: []string{"discord.asar"}, want: "discord.asar", original: "app.asar"},
+		{name: "renamed, stale patch", asars: []string{"discord.asar", "_app.asar"}, want: "discord.asar", original: "app.asar"},
+		{name: "app.asar next to another", asars: []string{"app.asar", "core.asar"}, want: "app.asar", original: "_app.asar"},
+		{name: "ambiguous", asars: []string{"discord.asar", "core.asar"}, want: "app.asar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			dir := path.Join(t.TempDir(), "discord")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.asars {
+				if err := asar.PackMap(path.Join(dir, name), vanilla, asar.Options{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range tt.stubs {
+				if err := os.WriteFile(path.Join(dir, name), stub, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := systemElectronAsar(dir); got != tt.want {
+				t.Errorf("systemElectronAsar is %s, want %s", got, tt.want)
+			}
+
+			di := ParseDiscord(dir, "stable")
+			if tt.original == "" {
+				if di != nil {
+					t.Errorf("parsed as an install running %s", di.appAsar())
+				}
+				return
+			}
+			if di == nil {
+				t.Fatal("not parsed as an install")
+			}
+			if !di.isSystemElectron {
+				t.Error("not a system Electron install")
+			}
+			if got, want := di.appAsar(), path.Join(dir, tt.want); got != want {
+				t.Errorf("app asar is %s, want %s", got, want)
+			}
+			if got, want := di.originalAsar(), path.Join(dir, tt.original); got != want {
+				t.Errorf("original asar is %s, want %s", got, want)
+			}
+		})
+	}
+}
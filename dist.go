@@ -0,0 +1,216 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	path "path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errNoExchange is returned by exchangeDirs if the platform or file system can't swap two folders in one go
+var errNoExchange = errors.New("exchanging folders is not supported")
+
+// stagingDir is where a new build is downloaded to. It only replaces FilesDir once it is complete
+func stagingDir() string {
+	return FilesDir + ".staging"
+}
+
+// rejectedDir is where a download that failed verification is kept for inspection
+func rejectedDir() string {
+	return FilesDir + ".rejected-" + time.Now().Format("20060102-150405")
+}
+
+// previousDistDir holds the previous build for a moment while swapDist moves the new one in, if the folders
+// can't be exchanged in one go
+func previousDistDir() string {
+	return FilesDir + ".old"
+}
+
+// recoverDist puts the previous build back if the installer died halfway through swapDist
+func recoverDist() {
+	if ExistsFile(FilesDir) || !ExistsFile(previousDistDir()) {
+		return
+	}
+	Log.Warn("Restoring the previous Vencord build from", previousDistDir(), "as the last update didn't finish")
+	if err := os.Rename(previousDistDir(), FilesDir); err != nil {
+		Log.Error("Failed to restore", previousDistDir()+":", err)
+	}
+}
+
+// downloadAsset downloads ass to dir and returns its sha256. Responses that are shorter than their
+// Content-Length are an error, the digest has to catch everything else
+func downloadAsset(ass GithubAsset, dir string) (string, error) {
+	Log.Debug("Downloading file", ass.Name)
+
+	res, err := http.Get(ass.DownloadURL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return "", errors.New(res.Status)
+	}
+
+	outFile := path.Join(dir, ass.Name)
+	out, err := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	read, err := io.Copy(io.MultiWriter(out, h), res.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to download to %s: %w", outFile, err)
+	}
+
+	// chunked responses don't have one
+	contentLength := res.Header.Get("Content-Length")
+	expected := strconv.FormatInt(read, 10)
+	if contentLength != "" && expected != contentLength {
+		return "", errors.New("Unexpected end of input. Content-Length was " + contentLength + ", but I only read " + expected)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stageBuild downloads the dist assets of rel into a fresh staging folder in parallel and makes sure the build is
+// complete and every asset matches its digest and looks like what its name says. Returns the staging folder.
+// If anything failed, it is deleted again, except if an asset failed verification. Then it is moved to a
+// folder of its own, so it can be inspected even after the next attempt
+func stageBuild(rel *releaseSnapshot) (_ string, err error) {
+	dir := stagingDir()
+	// leftovers of an update that was interrupted
+	if err = os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	defer func() {
+		var verifyErr *AssetVerifyError
+		if err == nil {
+			return
+		}
+		if errors.As(err, &verifyErr) {
+			rejected := unusedPath(rejectedDir())
+			if renameErr := os.Rename(dir, rejected); renameErr != nil {
+				Log.Warn("Failed to move the rejected download out of", dir+":", renameErr)
+				return
+			}
+			verifyErr.Staged = rejected
+			_ = FixOwnership(rejected)
+			return
+		}
+		_ = os.RemoveAll(dir)
+	}()
+
+	assets := rel.distAssets()
+	digests, err := expectedDigests(&rel.release, assets)
+	if err != nil {
+		return "", err
+	}
+
+	// create an empty package.json file in our files dir.
+	// without this, node will walk up the file tree and search for a package.json in the
+	// parent folders. This might lead to issues if the user for example has ~/package.json
+	// with type: "module" in it
+	if err = os.WriteFile(path.Join(dir, "package.json"), []byte("{}"), 0644); err != nil {
+		return "", err
+	}
+
+	errs := make([]error, len(assets))
+	sums := make([]string, len(assets))
+	var wg sync.WaitGroup
+	for i, ass := range assets {
+		wg.Add(1)
+		i, ass := i, ass // Need to do this to not have the variable be overwritten halfway through
+		go func() {
+			defer wg.Done()
+			if sums[i], errs[i] = downloadAsset(ass, dir); errs[i] != nil {
+				Log.Error("Failed to download", ass.Name+":", errs[i])
+				errs[i] = fmt.Errorf("Failed to download %s: %w", ass.Name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, e := range errs {
+		if e != nil {
+			return "", e
+		}
+	}
+	for i, ass := range assets {
+		if expected, ok := digests[ass.Name]; !ok {
+			if !AllowUnverified {
+				return "", &AssetVerifyError{ass.Name, "the release doesn't say which sha256 it should have, neither on GitHub nor in a checksum file. " +
+					"To install it anyway, set VENCORD_ALLOW_UNVERIFIED=1 or pass --allow-unverified", dir}
+			}
+			Log.Warn("The release doesn't say which sha256", ass.Name, "should have, only checking its content as unverified assets are allowed")
+		} else if sums[i] != expected {
+			return "", &AssetVerifyError{ass.Name, fmt.Sprintf("its sha256 is %s, but the release says %s", sums[i], expected), dir}
+		}
+		if problem := validateAsset(path.Join(dir, ass.Name), ass); problem != "" {
+			return "", &AssetVerifyError{ass.Name, problem, dir}
+		}
+		Log.Debug("Verified", ass.Name)
+	}
+	for _, name := range rel.source.Assets {
+		if !ExistsFile(path.Join(dir, name)) {
+			return "", errors.New("The release of " + rel.source.Name + " doesn't contain " + name)
+		}
+	}
+	return dir, FixOwnership(dir)
+}
+
+// swapDist makes the complete build in staged the one in FilesDir and deletes the previous build. Discord only
+// ever sees either build as a whole, and if anything fails, the previous build stays in place
+func swapDist(staged string) error {
+	if !ExistsFile(FilesDir) {
+		return os.Rename(staged, FilesDir)
+	}
+
+	err := exchangeDirs(staged, FilesDir)
+	if err == nil {
+		// staged is the previous build now
+		if err = os.RemoveAll(staged); err != nil {
+			Log.Warn("Failed to delete the previous Vencord build at", staged+":", err)
+		}
+		return nil
+	}
+	if !errors.Is(err, errNoExchange) {
+		return err
+	}
+
+	// Two renames instead, recoverDist cleans up if we die in between
+	old := previousDistDir()
+	if err = os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err = os.Rename(FilesDir, old); err != nil {
+		return fmt.Errorf("Failed to move the previous Vencord build out of the way: %w", err)
+	}
+	if err = os.Rename(staged, FilesDir); err != nil {
+		if undoErr := os.Rename(old, FilesDir); undoErr != nil {
+			Log.Error("Failed to restore the previous Vencord build from", old+":", undoErr)
+		}
+		return fmt.Errorf("Failed to move the new Vencord build into place: %w", err)
+	}
+	if err = os.RemoveAll(old); err != nil {
+		Log.Warn("Failed to delete the previous Vencord build at", old+":", err)
+	}
+	return nil
+}
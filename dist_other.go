@@ -0,0 +1,14 @@
+//go:build !linux
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+// exchangeDirs atomically swaps the folders a and b
+func exchangeDirs(a, b string) error {
+	return errNoExchange
+}
@@ -0,0 +1,238 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	path "path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var testBuild = map[string]string{
+	"patcher.js":   "require('electron')",
+	"preload.js":   "window.x = 1",
+	"renderer.js":  "console.log('Vencord')",
+	"renderer.css": "body { color: red }",
+}
+
+// serveAssets serves the content of files by their name
+func serveAssets(t *testing.T, files map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[path.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// testRelease returns a release of the assets named in digests, downloaded from srv, reporting the digests
+func testRelease(srv *httptest.Server, digests map[string]string) *releaseSnapshot {
+	rel := &releaseSnapshot{
+		source: &Source{Id: "test", Name: "Test", Assets: vencordAssets},
+		hash:   "aaaaaaa",
+	}
+	for name, digest := range digests {
+		rel.release.Assets = append(rel.release.Assets, GithubAsset{Name: name, DownloadURL: srv.URL + "/" + name, Digest: digest})
+	}
+	return rel
+}
+
+// listDir returns the names in dir, nil if it doesn't exist
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := SliceMap(entries, func(e os.DirEntry) string { return e.Name() })
+	sort.Strings(names)
+	return names
+}
+
+func TestStageBuild(t *testing.T) {
+	complete := map[string]string{}
+	for name, content := range testBuild {
+		complete[name] = "sha256:" + sha256Hex(content)
+	}
+	without := func(name string) map[string]string {
+		digests := make(map[string]string)
+		for n, d := range complete {
+			if n != name {
+				digests[n] = d
+			}
+		}
+		return digests
+	}
+	// with returns complete, but name reports digest
+	with := func(name, digest string) map[string]string {
+		digests := without(name)
+		digests[name] = digest
+		return digests
+	}
+	// replaced returns testBuild, but name has content
+	replaced := func(name, content string) map[string]string {
+		files := map[string]string{name: content}
+		for n, c := range testBuild {
+			if n != name {
+				files[n] = c
+			}
+		}
+		return files
+	}
+	loginPage := "<html><body>Log in to the network</body></html>"
+
+	tests := []struct {
+		name            string
+		files           map[string]string
+		digests         map[string]string
+		allowUnverified bool
+		wantErr         bool
+		// the error has to be an AssetVerifyError, with the download kept
+		wantRejected bool
+	}{
+		{name: "complete", files: testBuild, digests: complete},
+		{name: "asset missing from the release", files: testBuild, digests: without("renderer.css"), wantErr: true},
+		{name: "download fails", files: map[string]string{"patcher.js": testBuild["patcher.js"]}, digests: complete, wantErr: true},
+		{name: "digest mismatch", files: replaced("patcher.js", "evil()"), digests: complete, wantErr: true, wantRejected: true},
+		{name: "no digest", files: testBuild, digests: with("patcher.js", ""), wantErr: true, wantRejected: true},
+		{name: "no digest, unverified allowed", files: testBuild, digests: with("patcher.js", ""), allowUnverified: true},
+		{name: "other digest", files: testBuild, digests: with("patcher.js", "sha1:"+sha256Hex("")[:40]), wantErr: true, wantRejected: true},
+		{
+			name:         "login page with its digest",
+			files:        replaced("renderer.js", loginPage),
+			digests:      with("renderer.js", "sha256:"+sha256Hex(loginPage)),
+			wantErr:      true,
+			wantRejected: true,
+		},
+		{
+			name:            "login page, unverified allowed",
+			files:           replaced("renderer.js", loginPage),
+			digests:         with("renderer.js", ""),
+			allowUnverified: true,
+			wantErr:         true,
+			wantRejected:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			srv := serveAssets(t, tt.files)
+			oldAllow := AllowUnverified
+			AllowUnverified = tt.allowUnverified
+			t.Cleanup(func() { AllowUnverified = oldAllow })
+
+			dir, err := stageBuild(testRelease(srv, tt.digests))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if ExistsFile(stagingDir()) {
+					t.Error("the failed download was left in", stagingDir())
+				}
+				var verifyErr *AssetVerifyError
+				if isVerify := errors.As(err, &verifyErr); isVerify != tt.wantRejected {
+					t.Fatalf("%v is a verification error: %v, want %v", err, isVerify, tt.wantRejected)
+				}
+				if tt.wantRejected && len(listDir(t, verifyErr.Staged)) != len(vencordAssets)+1 {
+					t.Errorf("the rejected download in %q has %v", verifyErr.Staged, listDir(t, verifyErr.Staged))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := append([]string{"package.json"}, vencordAssets...)
+			sort.Strings(want)
+			if got := listDir(t, dir); !reflect.DeepEqual(got, want) {
+				t.Errorf("staged %v, want %v", got, want)
+			}
+			for name, content := range tt.files {
+				if b, _ := os.ReadFile(path.Join(dir, name)); string(b) != content {
+					t.Errorf("staged %s is %q, want %q", name, b, content)
+				}
+			}
+		})
+	}
+}
+
+func TestSwapDist(t *testing.T) {
+	for _, previous := range []bool{false, true} {
+		t.Run(Ternary(previous, "replace", "fresh"), func(t *testing.T) {
+			useTempBaseDir(t)
+			if previous {
+				writeFile(t, path.Join(FilesDir, "patcher.js"), "old")
+				writeFile(t, path.Join(FilesDir, "old.js"), "old")
+			}
+			writeFile(t, path.Join(stagingDir(), "patcher.js"), "new")
+
+			if err := swapDist(stagingDir()); err != nil {
+				t.Fatal(err)
+			}
+			if got := listDir(t, FilesDir); !reflect.DeepEqual(got, []string{"patcher.js"}) {
+				t.Errorf("dist has %v, want only the new build", got)
+			}
+			if b, _ := os.ReadFile(path.Join(FilesDir, "patcher.js")); string(b) != "new" {
+				t.Errorf("patcher.js is %q, want the new one", b)
+			}
+			for _, dir := range []string{stagingDir(), previousDistDir()} {
+				if ExistsFile(dir) {
+					t.Error(dir, "was left behind")
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverDist(t *testing.T) {
+	tests := []struct {
+		name               string
+		dist, previous     bool
+		wantDist, wantPrev string
+	}{
+		{name: "interrupted swap", previous: true, wantDist: "previous"},
+		{name: "nothing to recover", dist: true, wantDist: "current"},
+		{name: "both", dist: true, previous: true, wantDist: "current", wantPrev: "previous"},
+		{name: "no build"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			if tt.dist {
+				writeFile(t, path.Join(FilesDir, "patcher.js"), "current")
+			}
+			if tt.previous {
+				writeFile(t, path.Join(previousDistDir(), "patcher.js"), "previous")
+			}
+
+			recoverDist()
+			for dir, want := range map[string]string{FilesDir: tt.wantDist, previousDistDir(): tt.wantPrev} {
+				if b, _ := os.ReadFile(path.Join(dir, "patcher.js")); string(b) != want {
+					t.Errorf("%s has %q, want %q", dir, b, want)
+				}
+			}
+		})
+	}
+}
@@ -13,10 +13,10 @@ import (
 )
 
 var macosNames = map[string]string{
-	"stable": "Discord.app",
-	"ptb":    "Discord PTB.app",
-	"canary": "Discord Canary.app",
-	"dev":    "Discord Development.app",
+	"stable":      "Discord.app",
+	"ptb":         "Discord PTB.app",
+	"canary":      "Discord Canary.app",
+	"development": "Discord Development.app",
 }
 
 func ParseDiscord(p, branch string) *DiscordInstall {
@@ -34,14 +34,15 @@ func ParseDiscord(p, branch string) *DiscordInstall {
 	}
 
 	app := path.Join(resources, "app")
-	return &DiscordInstall{
+	di := &DiscordInstall{
 		path:             p,
 		branch:           branch,
 		appPath:          app,
-		isPatched:        ExistsFile(path.Join(resources, "_app.asar")),
 		isFlatpak:        false,
 		isSystemElectron: false,
 	}
+	di.refreshState()
+	return di
 }
 
 func FindDiscords() []any {
@@ -62,7 +63,16 @@ func FindDiscords() []any {
 	return discords
 }
 
-func PreparePatch(di *DiscordInstall) {}
+// desktopCoreFiles returns the discord_desktop_core/index.js of every Discord version that was ever run
+func (di *DiscordInstall) desktopCoreFiles() []string {
+	name := strings.ToLower(strings.ReplaceAll(strings.TrimSuffix(macosNames[di.branch], ".app"), " ", ""))
+	files, _ := path.Glob(path.Join(os.Getenv("HOME"), "Library/Application Support", name, "*", "modules", "discord_desktop_core", "index.js"))
+	return files
+}
+
+func PreparePatch(di *DiscordInstall) error {
+	return nil
+}
 
 func FixOwnership(_ string) error {
 	return nil
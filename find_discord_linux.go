@@ -53,56 +53,134 @@ func init() {
 
 	DiscordDirs = []string{
 		"/usr/share",
+		"/usr/lib",
 		"/usr/lib64",
+		"/usr/local/share",
+		"/usr/local/lib",
+		"/usr/local",
 		"/opt",
 		path.Join(Home, ".local/share"),
+		path.Join(Home, ".local/opt"),
 		path.Join(Home, ".dvm"),
-		"/var/lib/flatpak/app",
-		path.Join(Home, "/.local/share/flatpak/app"),
+		path.Join(Home, "Applications"),
+		// tarballs unpacked where they were downloaded
+		path.Join(Home, "Downloads"),
 	}
 }
 
-func ParseDiscord(p, _ string) *DiscordInstall {
+func ParseDiscord(p, branch string) *DiscordInstall {
+	di := &DiscordInstall{}
+	// the asar a system Electron runs, found in its launcher
+	if info, err := os.Stat(p); err == nil && !info.IsDir() && strings.HasSuffix(p, ".asar") {
+		p, di.asarName = path.Dir(p), path.Base(p)
+	}
 	name := path.Base(p)
 
-	needsFlatpakResolve := strings.Contains(p, "/flatpak/") && !strings.Contains(p, "/current/active/files/")
-	if needsFlatpakResolve {
-		discordName := strings.ToLower(name[len("com.discordapp."):])
-		if discordName != "discord" { //
-			// DiscordCanary -> discord-canary
-			discordName = discordName[:7] + "-" + discordName[7:]
+	if appDir := flatpakAppOf(p); appDir != "" {
+		if !di.parseFlatpak(appDir) {
+			Log.Warn("The Flatpak", appDir, "doesn't contain a Discord install")
+			return nil
 		}
-		p = path.Join(p, "current/active/files", discordName)
+		p, name = di.path, di.flatpakId
+	}
+
+	// Flatpak's current/active symlinks change with every update, so only resolve other installs
+	alias := ""
+	if real, err := path.EvalSymlinks(p); err == nil && real != p && !di.isFlatpak {
+		alias, p = p, real
 	}
 
 	resources := path.Join(p, "resources")
 	app := path.Join(resources, "app")
 
-	isPatched, isSystemElectron := false, false
-
-	if ExistsFile(resources) { // normal install
-		isPatched = ExistsFile(path.Join(resources, "_app.asar"))
-	} else if ExistsFile(path.Join(p, "app.asar")) { // System electron doesn't have resources folder
-		isSystemElectron = true
-		isPatched = ExistsFile(path.Join(p, "_app.asar.unpacked"))
-	} else {
+	// System electron doesn't have resources folder. The original asar of a patched install counts too, so
+	// installs with a missing app.asar still show up as broken
+	isSystemElectron := !ExistsFile(resources)
+	if isSystemElectron && di.asarName == "" {
+		di.asarName = systemElectronAsar(p)
+	}
+	if di.asarName == "app.asar" || !isSystemElectron {
+		di.asarName = ""
+	}
+	appAsar := path.Join(p, Ternary(di.asarName != "", di.asarName, "app.asar"))
+	if isSystemElectron && !ExistsFile(appAsar) && !ExistsFile(originalAsarOf(appAsar)) {
 		Log.Warn("Tried to parse invalid Location:", p)
 		return nil
 	}
 
-	return &DiscordInstall{
-		path:             p,
-		branch:           GetBranch(name),
-		appPath:          app,
-		isPatched:        isPatched,
-		isFlatpak:        needsFlatpakResolve,
-		isSystemElectron: isSystemElectron,
+	if branch == "" {
+		branch = GetBranch(name)
+	}
+
+	di.path = p
+	di.branch = branch
+	di.appPath = app
+	di.isSystemElectron = isSystemElectron
+	di.store = storeOf(p)
+	if alias != "" {
+		di.addAlias(alias)
+	}
+	di.refreshState()
+	return di
+}
+
+// systemElectronAsar returns the name of the asar a system Electron runs from dir. That is app.asar, unless
+// its launcher names it differently. Then it is the only other asar. Patching moves that one to app.asar, so
+// if app.asar exists as well, the other one is only it if it is our stub
+func systemElectronAsar(dir string) string {
+	asars, _ := path.Glob(path.Join(dir, "*.asar"))
+	asars = SliceFilter(asars, func(a string) bool { return path.Base(a) != "_app.asar" && path.Base(a) != "app.asar" })
+	if len(asars) != 1 || (ExistsFile(path.Join(dir, "app.asar")) && !IsStubAsar(asars[0])) {
+		return "app.asar"
 	}
+	return path.Base(asars[0])
 }
 
+// FindDiscords looks for Discord installs in DiscordDirs and the extra search dirs, then for the installs
+// Nix and Guix profiles link to and the ones launched by .desktop files. Installs found more than once are
+// only returned once
 func FindDiscords() []any {
 	var discords []any
-	for _, dir := range DiscordDirs {
+	seen := make(map[string]bool)
+	byIdentity := make(map[installIdentity]*DiscordInstall)
+	add := func(dir, branch string) {
+		dir = path.Clean(dir)
+		if seen[dir] {
+			return
+		}
+		seen[dir] = true
+
+		discord := ParseDiscord(dir, branch)
+		if discord == nil {
+			return
+		}
+		id, ok := identityOf(discord)
+		if existing := byIdentity[id]; ok && existing != nil {
+			for _, p := range discord.Paths() {
+				existing.addAlias(p)
+			}
+			Log.Debug(dir, "is the same install as", existing.path)
+			return
+		}
+		if seen[discord.path] && discord.path != dir {
+			return
+		}
+		seen[discord.path] = true
+		if ok {
+			byIdentity[id] = discord
+		}
+		Log.Debug("Found Discord install at ", dir)
+		discords = append(discords, discord)
+	}
+
+	extraDirs := extraSearchDirs()
+	for _, dir := range extraDirs {
+		if isInstallRoot(dir) {
+			add(dir, "")
+		}
+	}
+
+	for _, dir := range append(DiscordDirs, extraDirs...) {
 		children, err := os.ReadDir(dir)
 		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
@@ -113,22 +191,50 @@ func FindDiscords() []any {
 
 		for _, child := range children {
 			name := child.Name()
-			if !child.IsDir() || !SliceContains(LinuxDiscordNames, name) {
+			if !SliceContains(LinuxDiscordNames, name) {
 				continue
 			}
-
-			discordDir := path.Join(dir, name)
-			if discord := ParseDiscord(discordDir, ""); discord != nil {
-				Log.Debug("Found Discord install at ", discordDir)
-				discords = append(discords, discord)
+			// follows symlinks, unlike child.IsDir
+			if info, err := os.Stat(path.Join(dir, name)); err != nil || !info.IsDir() {
+				continue
 			}
+
+			add(path.Join(dir, name), "")
 		}
 	}
 
+	for _, app := range flatpakApps() {
+		add(app, "")
+	}
+
+	for _, c := range append(append(launcherInstalls(), storeProfileInstalls()...), desktopEntryInstalls()...) {
+		add(c.dir, c.branch)
+	}
+
 	return discords
 }
 
-func PreparePatch(di *DiscordInstall) {}
+// configDirNames are the names of the Discord config folders in ~/.config, by branch
+var configDirNames = map[string]string{
+	"stable":      "discord",
+	"ptb":         "discordptb",
+	"canary":      "discordcanary",
+	"development": "discorddevelopment",
+}
+
+// desktopCoreFiles returns the discord_desktop_core/index.js of every Discord version that was ever run
+func (di *DiscordInstall) desktopCoreFiles() []string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" || os.Getenv("SUDO_USER") != "" {
+		configDir = path.Join(Home, ".config")
+	}
+	if di.isFlatpak {
+		configDir = path.Join(Home, ".var/app", di.flatpakAppId(), "config")
+	}
+
+	files, _ := path.Glob(path.Join(configDir, configDirNames[di.branch], "*", "modules", "discord_desktop_core", "index.js"))
+	return files
+}
 
 // FixOwnership fixes file ownership on Linux
 func FixOwnership(p string) error {
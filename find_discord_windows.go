@@ -17,10 +17,10 @@ import (
 )
 
 var windowsNames = map[string]string{
-	"stable": "Discord",
-	"ptb":    "DiscordPTB",
-	"canary": "DiscordCanary",
-	"dev":    "DiscordDevelopment",
+	"stable":      "Discord",
+	"ptb":         "DiscordPTB",
+	"canary":      "DiscordCanary",
+	"development": "DiscordDevelopment",
 }
 
 var killLock sync.Mutex
@@ -34,7 +34,6 @@ func ParseDiscord(p, branch string) *DiscordInstall {
 		return nil
 	}
 
-	isPatched := false
 	appPath := ""
 	for _, dir := range entries {
 		if dir.IsDir() && strings.HasPrefix(dir.Name(), "app-") {
@@ -45,7 +44,6 @@ func ParseDiscord(p, branch string) *DiscordInstall {
 			app := path.Join(resources, "app")
 			if app > appPath {
 				appPath = app
-				isPatched = ExistsFile(path.Join(resources, "_app.asar"))
 			}
 		}
 	}
@@ -58,14 +56,15 @@ func ParseDiscord(p, branch string) *DiscordInstall {
 		branch = GetBranch(p)
 	}
 
-	return &DiscordInstall{
+	di := &DiscordInstall{
 		path:             p,
 		branch:           branch,
 		appPath:          appPath,
-		isPatched:        isPatched,
 		isFlatpak:        false,
 		isSystemElectron: false,
 	}
+	di.refreshState()
+	return di
 }
 
 func FindDiscords() []any {
@@ -87,7 +86,14 @@ func FindDiscords() []any {
 	return discords
 }
 
-func PreparePatch(di *DiscordInstall) {
+// desktopCoreFiles returns the discord_desktop_core/index.js of the Discord version that is in use
+func (di *DiscordInstall) desktopCoreFiles() []string {
+	// app-1.0.9013/modules/discord_desktop_core-1/discord_desktop_core/index.js
+	files, _ := path.Glob(path.Join(di.appPath, "..", "..", "modules", "discord_desktop_core-*", "discord_desktop_core", "index.js"))
+	return files
+}
+
+func PreparePatch(di *DiscordInstall) error {
 	killLock.Lock()
 	defer killLock.Unlock()
 	
@@ -96,13 +102,13 @@ func PreparePatch(di *DiscordInstall) {
 	pid := findProcessIdByName(name + ".exe")
 	if pid == 0 {
 		Log.Debug("Didn't find process matching name")
-		return
+		return nil
 	}
 
 	proc, err := os.FindProcess(int(pid))
 	if err != nil {
 		Log.Warn("Failed to find process with pid", pid)
-		return
+		return nil
 	}
 
 	err = proc.Kill()
@@ -112,6 +118,7 @@ func PreparePatch(di *DiscordInstall) {
 		Log.Debug("Waiting for", name, "to exit")
 		_, _ = proc.Wait()
 	}
+	return nil
 }
 
 func FixOwnership(_ string) error {
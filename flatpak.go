@@ -0,0 +1,335 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	path "path/filepath"
+	"strings"
+)
+
+// Flatpak modes, how the Discord Flatpak gets to read Vencord's files
+const (
+	// FlatpakModeOverride grants the Flatpak access to FilesDir with flatpak override
+	FlatpakModeOverride = "override"
+	// FlatpakModeSandbox copies the dist into the data folder of the Flatpak, which it may read anyway
+	FlatpakModeSandbox = "sandbox"
+)
+
+// FlatpakMode is the mode patching uses for Flatpaks. Empty keeps the mode each install already uses
+var FlatpakMode = ""
+
+// flatpakAppId returns the app id like com.discordapp.Discord of a flatpak install
+func (di *DiscordInstall) flatpakAppId() string {
+	return di.flatpakId
+}
+
+// flatpakCommand returns the flatpak command line for args, operating on the installation di is part of.
+// If that is a user installation but we are root, runAs is the user it has to run as
+func (di *DiscordInstall) flatpakCommand(args ...string) (cmd []string, runAs string) {
+	cmd = []string{"flatpak"}
+	switch di.flatpakInstallation {
+	case "user":
+		cmd = append(cmd, "--user")
+		if os.Getuid() == 0 {
+			runAs = os.Getenv("SUDO_USER")
+		}
+	case "system", "":
+	default:
+		cmd = append(cmd, "--installation="+di.flatpakInstallation)
+	}
+	return append(cmd, args...), runAs
+}
+
+// FlatpakOverrideFilesystems returns the filesystems the overrides of di grant access to
+func (di *DiscordInstall) FlatpakOverrideFilesystems() ([]string, error) {
+	args, runAs := di.flatpakCommand("override", "--show", di.flatpakAppId())
+	out, err := stepCommandOutput(args, runAs)
+	if err != nil {
+		return nil, err
+	}
+	return parseFlatpakFilesystems(out)
+}
+
+// parseFlatpakFilesystems returns the filesystems in the output of flatpak override --show:
+//
+//	[Context]
+//	filesystems=/home/user/.config/Vencord/dist;xdg-download:ro;
+func parseFlatpakFilesystems(out []byte) ([]string, error) {
+	var filesystems []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if value, ok := strings.CutPrefix(line, "filesystems="); ok {
+			for _, fs := range strings.Split(value, ";") {
+				if fs != "" {
+					filesystems = append(filesystems, fs)
+				}
+			}
+		}
+	}
+	return filesystems, scanner.Err()
+}
+
+// HasFlatpakOverride reports whether the Discord flatpak may read dir
+func (di *DiscordInstall) HasFlatpakOverride(dir string) (bool, error) {
+	filesystems, err := di.FlatpakOverrideFilesystems()
+	if err != nil {
+		return false, err
+	}
+	return flatpakGrants(filesystems, dir), nil
+}
+
+// flatpakGrants reports whether the override filesystems grant access to dir. Entries starting with ! are
+// what --nofilesystem leaves behind, they deny access
+func flatpakGrants(filesystems []string, dir string) bool {
+	// the Linux init points HOME to the actual user when running with sudo
+	home := os.Getenv("HOME")
+	return SliceContainsFunc(filesystems, func(fs string) bool {
+		fs, _, _ = strings.Cut(fs, ":")
+		switch {
+		case fs == "host":
+			return true
+		case fs == "home":
+			fs = home
+		case strings.HasPrefix(fs, "~/"):
+			fs = path.Join(home, fs[2:])
+		}
+		return fs == dir || strings.HasPrefix(dir, strings.TrimSuffix(fs, "/")+"/")
+	})
+}
+
+// flatpakOverrideKey identifies the app and installation of di in InstallerState.FlatpakOverrides
+func (di *DiscordInstall) flatpakOverrideKey() string {
+	return Ternary(di.flatpakInstallation != "", di.flatpakInstallation, "system") + ":" + di.flatpakAppId()
+}
+
+// ownFlatpakOverrides returns the filesystems we granted di that are still in its overrides. That is only the
+// one the state remembers for its installation and app id, which differs from FilesDir if that changed since.
+// Everything else the user granted themselves, even if it is FilesDir
+func (di *DiscordInstall) ownFlatpakOverrides(filesystems []string) []string {
+	if dir := GetState().FlatpakOverrides[di.flatpakOverrideKey()]; dir != "" && SliceContains(filesystems, dir) {
+		return []string{dir}
+	}
+	return nil
+}
+
+// ownsFlatpakOverride reports whether the access of di to FilesDir is ours once plan ran: either plan grants it
+// or we granted it earlier. Access the user granted themselves is theirs to revoke
+func (di *DiscordInstall) ownsFlatpakOverride(plan *Plan) bool {
+	grant := "--filesystem=" + FilesDir
+	for _, step := range plan.Steps {
+		if step.Kind == StepCommand && SliceContains(step.Args, grant) {
+			return true
+		}
+	}
+	return GetState().FlatpakOverrides[di.flatpakOverrideKey()] == FilesDir
+}
+
+func (di *DiscordInstall) planFlatpakOverrideCommand(plan *Plan, option, description string) {
+	args, runAs := di.flatpakCommand("override", option, di.flatpakAppId())
+	step := plan.Command(args...)
+	step.Description = description
+	step.RunAs = runAs
+}
+
+// planFlatpakOverride makes sure the Discord Flatpak may read FilesDir, and revokes the access we granted to
+// an older FilesDir. Overrides the user added themselves are left alone
+func (di *DiscordInstall) planFlatpakOverride(plan *Plan) {
+	filesystems, err := di.FlatpakOverrideFilesystems()
+	if err != nil {
+		Log.Warn("Failed to read the overrides of", di.flatpakAppId()+", granting access anyway:", err)
+	}
+
+	for _, dir := range di.ownFlatpakOverrides(filesystems) {
+		if dir != FilesDir {
+			di.planFlatpakOverrideCommand(plan, "--nofilesystem="+dir, "revoke the access of the Discord Flatpak to the outdated "+dir)
+		}
+	}
+	if err != nil || !flatpakGrants(filesystems, FilesDir) {
+		di.planFlatpakOverrideCommand(plan, "--filesystem="+FilesDir, "grant the Discord Flatpak access to "+FilesDir)
+	}
+}
+
+// planRevokeFlatpakOverride revokes the access to Vencord's files patching granted to the Discord Flatpak.
+// flatpak can't remove a single override, so this leaves a ! entry denying access, which is the default anyway
+func (di *DiscordInstall) planRevokeFlatpakOverride(plan *Plan) {
+	filesystems, err := di.FlatpakOverrideFilesystems()
+	if err != nil {
+		Log.Warn("Failed to read the overrides of", di.flatpakAppId()+", not revoking its access to", FilesDir+":", err)
+		return
+	}
+	for _, dir := range di.ownFlatpakOverrides(filesystems) {
+		di.planFlatpakOverrideCommand(plan, "--nofilesystem="+dir, "revoke the access of the Discord Flatpak to "+dir)
+	}
+}
+
+// trackFlatpakOverride remembers whether we granted di access to FilesDir, so unpatching can revoke it even
+// after FilesDir changed
+func (di *DiscordInstall) trackFlatpakOverride(granted bool) {
+	err := UpdateState(func(s *InstallerState) {
+		if !granted {
+			delete(s.FlatpakOverrides, di.flatpakOverrideKey())
+			return
+		}
+		if s.FlatpakOverrides == nil {
+			s.FlatpakOverrides = map[string]string{}
+		}
+		s.FlatpakOverrides[di.flatpakOverrideKey()] = FilesDir
+	})
+	if err != nil {
+		Log.Warn("Failed to remember the Flatpak override of", di.path+":", err)
+	}
+}
+
+// sandboxFilesDir is where the sandbox mode copies the dist to. The Flatpak sees its data folder at the same
+// path, and Vencord keeps its settings next to it
+func (di *DiscordInstall) sandboxFilesDir() string {
+	return path.Join(os.Getenv("HOME"), ".var/app", di.flatpakAppId(), "config/Vencord/dist")
+}
+
+// CurrentFlatpakMode returns the mode the Flatpak di was patched with, "" if it isn't patched
+func (di *DiscordInstall) CurrentFlatpakMode() string {
+	target, err := ReadStubTarget(di.appAsar())
+	if err != nil || target == "" {
+		return ""
+	}
+	return Ternary(strings.HasPrefix(target, di.sandboxFilesDir()+"/"), FlatpakModeSandbox, FlatpakModeOverride)
+}
+
+// flatpakMode returns the mode patching the Flatpak di uses
+func (di *DiscordInstall) flatpakMode() string {
+	if FlatpakMode != "" {
+		return FlatpakMode
+	}
+	if mode := di.CurrentFlatpakMode(); mode != "" {
+		return mode
+	}
+	return FlatpakModeOverride
+}
+
+// patcherPath returns the patcher the stub of di has to require
+func (di *DiscordInstall) patcherPath() string {
+	if di.isFlatpak && di.flatpakMode() == FlatpakModeSandbox {
+		return path.Join(di.sandboxFilesDir(), "patcher.js")
+	}
+	return Patcher
+}
+
+// planFlatpak lets the Discord Flatpak read Vencord's files the way its mode wants, and undoes the other mode
+func (di *DiscordInstall) planFlatpak(plan *Plan) {
+	dir := di.sandboxFilesDir()
+	if di.flatpakMode() == FlatpakModeSandbox {
+		di.planSandboxDist(plan)
+		di.planRevokeFlatpakOverride(plan)
+		return
+	}
+
+	di.planFlatpakOverride(plan)
+	if ExistsFile(dir) {
+		plan.Remove(dir).Description = "copy of Vencord in the Flatpak sandbox, which the override mode doesn't use"
+	}
+}
+
+// planSandboxDist copies FilesDir into the sandbox of di, replacing the previous copy
+func (di *DiscordInstall) planSandboxDist(plan *Plan) {
+	dir := di.sandboxFilesDir()
+	old := dir + ".old"
+	if ExistsFile(old) {
+		plan.Remove(old).Description = "leftover copy of Vencord from an unfinished update"
+	}
+
+	replace := ExistsFile(dir)
+	if replace {
+		plan.Rename(dir, old)
+	}
+	plan.Copy(FilesDir, dir).Description = "copy of Vencord inside the Flatpak sandbox"
+	if replace {
+		plan.Remove(old)
+	}
+}
+
+// SyncSandboxDists copies FilesDir into the sandboxes of the installs using the sandbox mode again, after it
+// changed without patching them
+func SyncSandboxDists(installs []*DiscordInstall) error {
+	for _, di := range installs {
+		if !di.isFlatpak || di.CurrentFlatpakMode() != FlatpakModeSandbox || di.sandboxDistProblem() == "" {
+			continue
+		}
+		plan := NewPlan("sync", di)
+		di.planSandboxDist(plan)
+		if err := plan.Execute(); err != nil {
+			return err
+		}
+		_ = FixOwnership(path.Dir(di.sandboxFilesDir()))
+		di.refreshState()
+	}
+	return nil
+}
+
+// sandboxDistProblem compares the copy of the dist in the sandbox of di with FilesDir. Returns "" if they match
+func (di *DiscordInstall) sandboxDistProblem() string {
+	dir := di.sandboxFilesDir()
+	for _, name := range InstalledSource().Assets {
+		expected, err := HashPath(path.Join(FilesDir, name))
+		if err != nil {
+			continue
+		}
+		hash, err := HashPath(path.Join(dir, name))
+		switch {
+		case err != nil:
+			return "the copy of Vencord in the sandbox is missing " + name
+		case hash != expected:
+			return "the copy of Vencord in the sandbox is outdated, " + name + " differs from " + FilesDir
+		}
+	}
+	return ""
+}
+
+// checkFlatpak adds the mode of di to s, and everything that doesn't match its state and mode
+func (di *DiscordInstall) checkFlatpak(s *InstallStatus) {
+	s.FlatpakMode = di.CurrentFlatpakMode()
+	patched := di.IsPatched() || s.State == StateBroken
+	if patched && s.FlatpakMode == FlatpakModeSandbox {
+		if problem := di.sandboxDistProblem(); problem != "" {
+			s.broken(problem, "install")
+		}
+	}
+
+	filesystems, err := di.FlatpakOverrideFilesystems()
+	if err != nil {
+		Log.Debug("Failed to read the overrides of", di.flatpakAppId()+":", err)
+		return
+	}
+
+	own := di.ownFlatpakOverrides(filesystems)
+	if patched && s.FlatpakMode == FlatpakModeSandbox {
+		if len(own) > 0 {
+			s.reason("the Flatpak may still read " + strings.Join(own, ", ") + ", which the sandbox mode doesn't need")
+		}
+	} else if patched {
+		if !flatpakGrants(filesystems, FilesDir) {
+			s.broken("the Flatpak may not read "+FilesDir, "install")
+		}
+		for _, dir := range own {
+			if dir != FilesDir {
+				s.reason("the Flatpak may still read the outdated " + dir)
+			}
+		}
+	} else if len(own) > 0 {
+		s.reason("the Flatpak may still read " + strings.Join(own, ", ") + ", granted by an earlier install")
+	}
+}
+
+// stepCommandOutput runs args like runStepCommand does, but returns what it printed
+func stepCommandOutput(args []string, runAs string) ([]byte, error) {
+	cmd := stepCommand(args, runAs)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
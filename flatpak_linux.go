@@ -0,0 +1,202 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bufio"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+)
+
+// flatpakInstallation is a folder Flatpak installs apps into. name is what flatpak calls it on the command
+// line: user, system, or the id of a custom installation for --installation
+type flatpakInstallation struct {
+	name string
+	path string
+}
+
+// readKeyFile reads a file in the ini like format of .desktop files and Flatpak config, by group and key
+func readKeyFile(file string) (map[string]map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups := make(map[string]map[string]string)
+	var group map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := line[1 : len(line)-1]
+			if groups[name] == nil {
+				groups[name] = make(map[string]string)
+			}
+			group = groups[name]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if group == nil || !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		group[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return groups, scanner.Err()
+}
+
+// flatpakInstallations returns the user and system installation, and the custom ones configured in
+// installations.d, the same way flatpak itself finds them
+func flatpakInstallations() []flatpakInstallation {
+	userDir := os.Getenv("FLATPAK_USER_DIR")
+	if userDir == "" || os.Getenv("SUDO_USER") != "" {
+		userDir = path.Join(Home, ".local/share/flatpak")
+	}
+	systemDir := os.Getenv("FLATPAK_SYSTEM_DIR")
+	if systemDir == "" {
+		systemDir = "/var/lib/flatpak"
+	}
+	configDir := os.Getenv("FLATPAK_CONFIG_DIR")
+	if configDir == "" {
+		configDir = "/etc/flatpak"
+	}
+
+	installations := []flatpakInstallation{{"user", userDir}, {"system", systemDir}}
+
+	files, _ := path.Glob(path.Join(configDir, "installations.d", "*.conf"))
+	sort.Strings(files)
+	for _, file := range files {
+		groups, err := readKeyFile(file)
+		if err != nil {
+			Log.Warn("Failed to read", file+":", err)
+			continue
+		}
+		for group, keys := range groups {
+			// [Installation "extra"]
+			name, ok := strings.CutPrefix(group, "Installation ")
+			if !ok || keys["Path"] == "" {
+				continue
+			}
+			installations = append(installations, flatpakInstallation{strings.Trim(name, `"`), keys["Path"]})
+		}
+	}
+	return installations
+}
+
+// flatpakDeployDir returns the folder of the active deploy of the Flatpak app at appDir, which contains its
+// metadata and files. current points to the arch and branch in use, so this works on every arch
+func flatpakDeployDir(appDir string) string {
+	if dir := path.Join(appDir, "current/active"); ExistsFile(path.Join(dir, "metadata")) {
+		return dir
+	}
+	// current is missing if the app is only installed for another arch, take whichever deploy there is
+	deploys, _ := path.Glob(path.Join(appDir, "*", "*", "active", "metadata"))
+	if len(deploys) == 0 {
+		return ""
+	}
+	return path.Dir(deploys[0])
+}
+
+// flatpakAppName reads the app id from the metadata of the deploy
+func flatpakAppName(deployDir string) string {
+	groups, err := readKeyFile(path.Join(deployDir, "metadata"))
+	if err != nil {
+		Log.Debug("Failed to read the metadata of", deployDir+":", err)
+		return ""
+	}
+	return groups["Application"]["name"]
+}
+
+// findInstallRoot looks for the base folder of the Discord install in the files of a deploy, instead of
+// guessing its name from the app id
+func findInstallRoot(dir string, depth int) string {
+	if isInstallRoot(dir) {
+		return dir
+	}
+	if depth == 0 {
+		return ""
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if root := findInstallRoot(path.Join(dir, e.Name()), depth-1); root != "" {
+				return root
+			}
+		}
+	}
+	return ""
+}
+
+// flatpakAppOf returns the app folder, like .../app/com.discordapp.Discord, if p is one or is inside the files
+// of one of its deploys
+func flatpakAppOf(p string) string {
+	// .../app/<id>/current/active/files or .../app/<id>/<arch>/<branch>/active/files
+	if before, _, ok := strings.Cut(p, "/active/files"); ok {
+		p = before
+		for i := 0; i < 2 && path.Base(path.Dir(p)) != "app"; i++ {
+			p = path.Dir(p)
+		}
+	}
+	if path.Base(path.Dir(p)) != "app" || flatpakDeployDir(p) == "" {
+		return ""
+	}
+	return p
+}
+
+// parseFlatpak fills in the Flatpak fields of di and points its path to the Discord install in the files of
+// the active deploy of appDir. That path goes through the current and active symlinks, so it survives updates.
+// Returns false if the app doesn't contain Discord
+func (di *DiscordInstall) parseFlatpak(appDir string) bool {
+	deploy := flatpakDeployDir(appDir)
+	root := findInstallRoot(path.Join(deploy, "files"), 3)
+	if root == "" {
+		return false
+	}
+	rel, err := path.Rel(deploy, root)
+	if err != nil {
+		return false
+	}
+
+	di.isFlatpak = true
+	di.path = path.Join(deploy, rel)
+	di.flatpakId = flatpakAppName(deploy)
+	if di.flatpakId == "" {
+		di.flatpakId = path.Base(appDir)
+	}
+
+	installDir := path.Dir(path.Dir(appDir))
+	di.flatpakInstallation = "system"
+	for _, inst := range flatpakInstallations() {
+		if path.Clean(inst.path) == installDir {
+			di.flatpakInstallation = inst.name
+			break
+		}
+	}
+	return true
+}
+
+// flatpakApps returns the app folders of every Flatpak in every installation that looks like Discord
+func flatpakApps() []string {
+	var apps []string
+	for _, inst := range flatpakInstallations() {
+		entries, err := os.ReadDir(path.Join(inst.path, "app"))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if strings.Contains(strings.ToLower(e.Name()), "discord") {
+				apps = append(apps, path.Join(inst.path, "app", e.Name()))
+			}
+		}
+	}
+	return apps
+}
@@ -0,0 +1,146 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseFlatpakFilesystems(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []string
+	}{
+		{"no overrides", "", nil},
+		{"no filesystems", "[Context]\nsockets=wayland;\n", nil},
+		{
+			name: "filesystems",
+			out:  "[Context]\nsockets=wayland;\nfilesystems=/home/user/.config/Vencord/dist;xdg-download:ro;!/opt/old;\n\n[Environment]\nFOO=filesystems=/x\n",
+			want: []string{"/home/user/.config/Vencord/dist", "xdg-download:ro", "!/opt/old"},
+		},
+		{"indented", "[Context]\n  filesystems=home\n", []string{"home"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFlatpakFilesystems([]byte(tt.out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filesystems are %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlatpakGrants(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	dir := "/home/user/.config/Vencord/dist"
+
+	tests := []struct {
+		filesystems []string
+		want        bool
+	}{
+		{nil, false},
+		{[]string{dir}, true},
+		{[]string{dir + ":ro"}, true},
+		{[]string{"/home/user/.config/"}, true},
+		{[]string{"~/.config"}, true},
+		{[]string{"home"}, true},
+		{[]string{"host"}, true},
+		{[]string{"!" + dir}, false},
+		{[]string{"/home/user/.config/Vencord/distant"}, false},
+		{[]string{"/home/user/.config/Vencord/dist/patcher.js"}, false},
+		{[]string{"xdg-download", "~/.var"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := flatpakGrants(tt.filesystems, dir); got != tt.want {
+			t.Errorf("flatpakGrants(%q) is %v, want %v", tt.filesystems, got, tt.want)
+		}
+	}
+}
+
+// fakeFlatpak puts a flatpak on PATH whose override --show prints filesystems
+func fakeFlatpak(t *testing.T, filesystems string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nprintf '[Context]\\nfilesystems=%s\\n' '" + filesystems + "'\n"
+	if err := os.WriteFile(path.Join(dir, "flatpak"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestPlanFlatpakOverride(t *testing.T) {
+	tests := []struct {
+		name        string
+		filesystems string
+		// the dir the state says we granted access to
+		tracked    string
+		wantPatch  []string
+		wantRevoke []string
+	}{
+		{name: "none", wantPatch: []string{"--filesystem=DIST"}},
+		{name: "granted by the user", filesystems: "DIST;"},
+		{name: "granted by the user through home", filesystems: "home;"},
+		{name: "ours", filesystems: "DIST;", tracked: "DIST", wantRevoke: []string{"--nofilesystem=DIST"}},
+		{
+			name:        "ours for an old dist",
+			filesystems: "/opt/old/dist;xdg-download;",
+			tracked:     "/opt/old/dist",
+			wantPatch:   []string{"--nofilesystem=/opt/old/dist", "--filesystem=DIST"},
+			wantRevoke:  []string{"--nofilesystem=/opt/old/dist"},
+		},
+		{name: "ours but removed by the user", tracked: "DIST", wantPatch: []string{"--filesystem=DIST"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			t.Setenv("HOME", BaseDir)
+			dist := func(s string) string { return strings.ReplaceAll(s, "DIST", FilesDir) }
+			fakeFlatpak(t, dist(tt.filesystems))
+
+			di := &DiscordInstall{path: "/var/lib/flatpak/app/com.discordapp.Discord", isFlatpak: true, flatpakId: "com.discordapp.Discord"}
+			if tt.tracked != "" {
+				if err := UpdateState(func(s *InstallerState) {
+					s.FlatpakOverrides = map[string]string{di.flatpakOverrideKey(): dist(tt.tracked)}
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// flatpak override <option> <app id>
+			options := func(plan *Plan) []string {
+				return SliceMap(plan.Steps, func(s *Step) string { return s.Args[2] })
+			}
+
+			plan := &Plan{}
+			di.planFlatpakOverride(plan)
+			if got, want := options(plan), SliceMap(tt.wantPatch, dist); !reflect.DeepEqual(got, want) {
+				t.Errorf("patching runs %q, want %q", got, want)
+			}
+
+			plan = &Plan{}
+			di.planRevokeFlatpakOverride(plan)
+			if got, want := options(plan), SliceMap(tt.wantRevoke, dist); !reflect.DeepEqual(got, want) {
+				t.Errorf("unpatching runs %q, want %q", got, want)
+			}
+		})
+	}
+}
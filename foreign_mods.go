@@ -0,0 +1,232 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	path "path/filepath"
+	"regexp"
+	"strings"
+)
+
+type ForeignModLayout string
+
+const (
+	// LayoutStub is a stub app.asar like ours, but requiring some other mod
+	LayoutStub ForeignModLayout = "stub"
+	// LayoutDesktopCore is a require injected into Discord's discord_desktop_core/index.js (BetterDiscord)
+	LayoutDesktopCore ForeignModLayout = "desktop-core"
+	// LayoutAppFolder is a resources/app folder, which Electron loads instead of app.asar
+	LayoutAppFolder ForeignModLayout = "app-folder"
+)
+
+// ForeignMod is another client mod found in a Discord install. Patching on top of it would at best load both
+// mods, at worst lose Discord's original app.asar, so it has to be removed first
+type ForeignMod struct {
+	Name   string           `json:"name"`
+	Layout ForeignModLayout `json:"layout"`
+	// The file or folder that loads the mod
+	Path string `json:"path"`
+	// What Path loads, if known
+	Target string `json:"target,omitempty"`
+}
+
+func (m *ForeignMod) String() string {
+	switch m.Layout {
+	case LayoutStub:
+		return m.Name + " (app.asar loads " + m.Target + ")"
+	case LayoutDesktopCore:
+		return m.Name + " (injected into " + m.Path + ")"
+	default:
+		return m.Name + " (" + m.Path + ")"
+	}
+}
+
+// Known mods by a path fragment they are installed to. Order matters, Equicord paths also contain vencord
+var knownMods = []struct{ fragment, name string }{
+	{"equicord", "Equicord"},
+	{"equibop", "Equicord"},
+	{"vencord", "Vencord"},
+	{"betterdiscord", "BetterDiscord"},
+	{"replugged", "Replugged"},
+	{"powercord", "Powercord"},
+	{"moonlight", "moonlight"},
+	{"shelter", "shelter"},
+}
+
+func identifyMod(target string) string {
+	lower := strings.ToLower(target)
+	for _, mod := range knownMods {
+		if strings.Contains(lower, mod.fragment) {
+			return mod.name
+		}
+	}
+	return "Unknown mod"
+}
+
+// IsOwnStubTarget reports whether a stub requiring target was installed by us (or the upstream Vencord installer,
+// which uses the same layout), also if it loads the dist of an older FilesDir. Those can simply be repaired
+func IsOwnStubTarget(target string) bool {
+	if target == Patcher {
+		return true
+	}
+	// Forks of the installer load a dist/patcher.js as well, but from a folder named after them
+	if name := identifyMod(target); name != "Unknown mod" {
+		return name == "Vencord"
+	}
+	return strings.HasSuffix(path.ToSlash(target), "/dist/patcher.js")
+}
+
+// isOwnStub reports whether the stub at asarPath requiring target is ours, either by its target or because it
+// is laid out exactly like the stubs we build
+func isOwnStub(asarPath, target string) bool {
+	if IsOwnStubTarget(target) {
+		return true
+	}
+	return identifyMod(target) == "Unknown mod" && hasStubLayout(asarPath, target)
+}
+
+// IsOwnStubAsar reports whether the asar at asarPath is a stub loading Vencord
+func IsOwnStubAsar(asarPath string) bool {
+	target, err := ReadStubTarget(asarPath)
+	return err == nil && target != "" && isOwnStub(asarPath, target)
+}
+
+const vanillaDesktopCore = "module.exports = require('./core.asar');"
+
+var requireRe = regexp.MustCompile(`require\(\s*(?:"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)')\s*\)`)
+
+// DetectForeignMod looks for the common layouts other client mods use. Returns nil if there are none
+func (di *DiscordInstall) DetectForeignMod() *ForeignMod {
+	appAsar := di.appAsar()
+	if target, err := ReadStubTarget(appAsar); err == nil && target != "" && !isOwnStub(appAsar, target) {
+		return &ForeignMod{Name: identifyMod(target), Layout: LayoutStub, Path: appAsar, Target: target}
+	}
+
+	if !di.isSystemElectron {
+		if info, err := os.Stat(di.appPath); err == nil && info.IsDir() {
+			mod := &ForeignMod{Name: "Unknown mod", Layout: LayoutAppFolder, Path: di.appPath}
+			var pkg appPackageJson
+			if b, err := os.ReadFile(path.Join(di.appPath, "package.json")); err == nil && json.Unmarshal(b, &pkg) == nil {
+				mod.Name = identifyMod(pkg.Name + " " + pkg.Description)
+			}
+			if b, err := os.ReadFile(path.Join(di.appPath, "index.js")); err == nil {
+				if targets := requireTargets(string(b)); len(targets) > 0 {
+					mod.Target = targets[0]
+					if mod.Name == "Unknown mod" {
+						mod.Name = identifyMod(mod.Target)
+					}
+				}
+			}
+			return mod
+		}
+	}
+
+	for _, file := range di.desktopCoreFiles() {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		content := strings.TrimSpace(string(b))
+		if content == vanillaDesktopCore {
+			continue
+		}
+		for _, target := range requireTargets(content) {
+			if target != "./core.asar" {
+				return &ForeignMod{Name: identifyMod(target), Layout: LayoutDesktopCore, Path: file, Target: target}
+			}
+		}
+	}
+
+	return nil
+}
+
+func requireTargets(js string) []string {
+	var targets []string
+	for _, match := range requireRe.FindAllStringSubmatch(js, -1) {
+		targets = append(targets, match[1]+match[2])
+	}
+	return targets
+}
+
+// originalAsarNames are the names mods rename Discord's app.asar to
+var originalAsarNames = []string{"_app.asar", "app.orig.asar", "app.asar.orig", "app.asar.original"}
+
+// PlanRemoveForeignMod computes the steps that undo what the foreign mod m did to di
+func (di *DiscordInstall) PlanRemoveForeignMod(m *ForeignMod) (*Plan, error) {
+	plan := NewPlan("removal of "+m.Name, di)
+
+	switch m.Layout {
+	case LayoutStub:
+		dir := di.asarDir()
+		for _, name := range originalAsarNames {
+			original := path.Join(dir, name)
+			if !ExistsFile(original) || IsStubAsar(original) {
+				continue
+			}
+			plan.Rename(m.Path, m.Path+".foreign")
+			plan.Rename(original, m.Path).Description = "restore Discord's original app.asar"
+			if di.isSystemElectron && ExistsFile(original+".unpacked") {
+				plan.Rename(original+".unpacked", m.Path+".unpacked")
+			}
+			plan.Remove(m.Path + ".foreign")
+			return plan, nil
+		}
+		return nil, errors.New(m.Name + " replaced app.asar, but Discord's original app.asar is nowhere to be found. Reinstall Discord, then try again")
+	case LayoutAppFolder:
+		plan.Rename(m.Path, m.Path+".foreign")
+		plan.Remove(m.Path + ".foreign")
+	case LayoutDesktopCore:
+		plan.Rename(m.Path, m.Path+".foreign")
+		plan.Write(m.Path, []byte(vanillaDesktopCore+"\n")).Description = "restore Discord's original discord_desktop_core/index.js"
+		plan.Remove(m.Path + ".foreign")
+	}
+	return plan, nil
+}
+
+// RemoveForeignMod removes the foreign mod found in di, so Vencord can be installed
+func (di *DiscordInstall) RemoveForeignMod() error {
+	m := di.foreignMod
+	if m == nil {
+		return nil
+	}
+	Log.Info("Removing", m.String(), "from", di.path+"...")
+
+	plan, err := di.PlanRemoveForeignMod(m)
+	if err != nil {
+		return err
+	}
+
+	if err = PreparePatch(di); err != nil {
+		Log.Error(err.Error())
+		return err
+	}
+
+	if err = plan.Execute(); err != nil {
+		return err
+	}
+
+	di.refreshState()
+	if di.foreignMod != nil {
+		if di.foreignMod.Path == m.Path {
+			return errors.New("Removed " + m.Name + ", but it is still detected at " + m.Path)
+		}
+		// Some mods use several layouts at once
+		return di.RemoveForeignMod()
+	}
+
+	Log.Info("Successfully removed", m.Name, "from", di.path)
+	return nil
+}
+
+// ErrForeignMod explains why we won't patch an install that has another mod
+func (di *DiscordInstall) ErrForeignMod() error {
+	return errors.New(di.path + " is modified by " + di.foreignMod.String() + ".\n" +
+		"Installing Vencord on top of it would break both. Uninstall " + di.foreignMod.Name + " first, or let the installer remove it")
+}
@@ -11,31 +11,49 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	path "path/filepath"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+type GithubAsset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+	Size        int64  `json:"size,omitempty"`
+	// like sha256:<hex>, only reported for assets uploaded since GitHub started computing them
+	Digest string `json:"digest,omitempty"`
+}
+
 type GithubRelease struct {
-	Name    string `json:"name"`
-	TagName string `json:"tag_name"`
-	Assets  []struct {
-		Name        string `json:"name"`
-		DownloadURL string `json:"browser_download_url"`
-	} `json:"assets"`
+	Name            string        `json:"name"`
+	TagName         string        `json:"tag_name"`
+	TargetCommitish string        `json:"target_commitish"`
+	PublishedAt     time.Time     `json:"published_at"`
+	Assets          []GithubAsset `json:"assets"`
 }
 
 var ReleaseData GithubRelease
 var GithubError error
 var GithubDoneChan chan bool
 
+// releaseSource is the source ReleaseData belongs to
+var releaseSource *Source
+
+// githubLock guards ReleaseData, releaseSource, GithubError, LatestHash and PinnedBuild, which FetchLatestRelease
+// publishes from its goroutine, and SelectedSource, which SetSource changes while installs might run
+var githubLock sync.Mutex
+
+// fetchGeneration counts calls to FetchLatestRelease, so a fetch that was superseded by another one drops its result
+var fetchGeneration int
+
 var InstalledHash = "v1.0.0"
 var LatestHash = "Unknown"
-var IsDevInstall bool
+
+// IsDevInstall means FilesDir holds a build of one's own, which is used as is instead of downloading one
+var IsDevInstall = os.Getenv("VENCORD_DEV_INSTALL") == "1"
 
 func GetGithubRelease(url, fallbackUrl string) (*GithubRelease, error) {
 	Log.Debug("Fetching", url)
@@ -62,13 +80,13 @@ func GetGithubRelease(url, fallbackUrl string) (*GithubRelease, error) {
 
 		// GitHub has a very strict 60 req/h rate limit and some (mostly indian) isps block github for some reason.
 		// If that is the case, try our fallback at https://vencord.dev/releases/project
-		if isRateLimitedOrBlocked && !triedFallback {
+		if isRateLimitedOrBlocked && fallbackUrl != "" && !triedFallback {
 			Log.Error(fmt.Sprintf("Failed to fetch %s (status code %d). Trying fallback url %s", url, res.StatusCode, fallbackUrl))
 			return GetGithubRelease(fallbackUrl, fallbackUrl)
 		}
 
 		err = errors.New(res.Status)
-		Log.Error(url, "returned Non-OK status", GithubError)
+		Log.Error(url, "returned Non-OK status", err)
 		return nil, err
 	}
 
@@ -85,32 +103,18 @@ func GetGithubRelease(url, fallbackUrl string) (*GithubRelease, error) {
 func InitGithubDownloader() {
 	GithubDoneChan = make(chan bool, 1)
 
-	IsDevInstall = os.Getenv("VENCORD_DEV_INSTALL") == "1"
+	if SelectedSource == nil {
+		// Keep using whatever was installed last, so repairing doesn't suddenly switch to another fork
+		SelectedSource = InstalledSource()
+	}
+
 	Log.Debug("Is Dev Install: ", IsDevInstall)
 	if IsDevInstall {
 		GithubDoneChan <- true
 		return
 	}
 
-	go func() {
-		// Make sure UI updates once the request either finished or failed
-		defer func() {
-			GithubDoneChan <- GithubError == nil
-		}()
-
-		data, err := GetGithubRelease(ReleaseUrl, ReleaseUrlFallback)
-		if err != nil {
-			GithubError = err
-			return
-		}
-
-		ReleaseData = *data
-
-		i := strings.LastIndex(data.Name, " ") + 1
-		LatestHash = data.Name[i:]
-		Log.Debug("Finished fetching GitHub Data")
-		Log.Debug("Latest hash is", LatestHash, "Local Install is", Ternary(LatestHash == InstalledHash, "up to date!", "outdated!"))
-	}()
+	FetchLatestRelease()
 
 	// Check hash of installed version if exists
 	f, err := os.Open(Patcher)
@@ -133,70 +137,185 @@ func InitGithubDownloader() {
 	}
 }
 
-func installLatestBuilds() (retErr error) {
+// FetchLatestRelease fetches the latest release of SelectedSource in the background, or the one it is pinned
+// to. GithubDoneChan receives whether it worked once it's done. Only the most recent fetch reports back
+func FetchLatestRelease() {
+	githubLock.Lock()
+	fetchGeneration++
+	generation := fetchGeneration
+	// Nobody might have read the result of the last fetch
+	select {
+	case <-GithubDoneChan:
+	default:
+	}
+	src := SelectedSource
+	GithubError = nil
+	LatestHash = "Unknown"
+	ReleaseData = GithubRelease{}
+	releaseSource = src
+	PinnedBuild = nil
+	githubLock.Unlock()
+
+	pin := PinnedVersion(src)
+
+	go func() {
+		var data *GithubRelease
+		var err error
+		if b := pinnedBuild(src, pin); pin != "" && b != nil {
+			Log.Debug(src.Name, "is pinned to", pin+", which is kept as", b.Id)
+			data = &GithubRelease{Name: b.Tag + " " + b.Hash, TagName: b.Tag}
+		} else if pin != "" {
+			Log.Debug("Fetching the release", pin, "of", src.Name)
+			data, err = FindRelease(src, pin)
+		} else {
+			Log.Debug("Fetching the latest release of", src.Name)
+			data, err = GetGithubRelease(src.ReleaseUrl, src.FallbackUrl)
+		}
+
+		githubLock.Lock()
+		defer githubLock.Unlock()
+		if generation != fetchGeneration {
+			Log.Debug("Dropping the release of", src.Name, "as it was fetched again in the meantime")
+			return
+		}
+		// Make sure UI updates once the request either finished or failed
+		defer func() {
+			GithubDoneChan <- GithubError == nil
+		}()
+		if err != nil {
+			GithubError = err
+			return
+		}
+
+		ReleaseData = *data
+		LatestHash = data.Hash()
+		if pin != "" {
+			PinnedBuild = pinnedBuild(src, pin)
+		}
+		Log.Debug("Finished fetching GitHub Data")
+		Log.Debug("Latest hash is", LatestHash, "Local Install is", Ternary(installedSourceId() == src.Id && LatestHash == InstalledHash, "up to date!", "outdated!"))
+	}()
+}
+
+// releaseSnapshot is the release FetchLatestRelease found, taken at once under githubLock. Installs use a single
+// snapshot throughout, so switching the source halfway through can't mix assets of two releases
+type releaseSnapshot struct {
+	source  *Source
+	release GithubRelease
+	hash    string
+	// kept build the pin resolved to, installed instead of downloading
+	pinnedBuild *Build
+	err         error
+}
+
+// latestRelease takes a snapshot of the release FetchLatestRelease found, or is still looking for
+func latestRelease() *releaseSnapshot {
+	githubLock.Lock()
+	defer githubLock.Unlock()
+	return &releaseSnapshot{
+		source:      Ternary(releaseSource != nil, releaseSource, SelectedSource),
+		release:     ReleaseData,
+		hash:        LatestHash,
+		pinnedBuild: PinnedBuild,
+		err:         GithubError,
+	}
+}
+
+// LatestReleaseStatus returns the hash of the release FetchLatestRelease found and its error, if it failed,
+// while it might still be running
+func LatestReleaseStatus() (string, error) {
+	githubLock.Lock()
+	defer githubLock.Unlock()
+	return LatestHash, GithubError
+}
+
+// SetSource switches to another source and fetches its latest release. Only installing or repairing actually
+// downloads from it
+func SetSource(src *Source) {
+	if src == SelectedSource {
+		return
+	}
+	Log.Info("Switching to", src.Name)
+	githubLock.Lock()
+	SelectedSource = src
+	githubLock.Unlock()
+	RefetchRelease()
+}
+
+// RefetchRelease fetches the release to install from SelectedSource again, after it or its pin changed
+func RefetchRelease() {
+	if IsDevInstall {
+		return
+	}
+	FetchLatestRelease()
+}
+
+// IsDistOutdated reports whether the dist in FilesDir needs to be downloaded again
+func IsDistOutdated() bool {
+	return latestRelease().isOutdated()
+}
+
+// isOutdated reports whether the dist in FilesDir is another build than r
+func (r *releaseSnapshot) isOutdated() bool {
+	return installedSourceId() != r.source.Id || r.hash != InstalledHash
+}
+
+// distAssets returns the assets of r that make up the Vencord dist
+func (r *releaseSnapshot) distAssets() []GithubAsset {
+	return SliceFilter(r.release.Assets, func(ass GithubAsset) bool {
+		return SliceContainsFunc(r.source.Assets, func(prefix string) bool {
+			return strings.HasPrefix(ass.Name, prefix)
+		})
+	})
+}
+
+// installLatestBuilds downloads the latest release into a staging folder and only swaps it in for the build in
+// FilesDir once every asset arrived completely. A failed update leaves the previous build untouched
+func installLatestBuilds() error {
+	rel := latestRelease()
+	if rel.err != nil {
+		return fmt.Errorf("Failed to fetch the release of %s: %w", rel.source.Name, rel.err)
+	}
+	if rel.pinnedBuild != nil {
+		return UseBuild(rel.pinnedBuild)
+	}
 	Log.Debug("Installing latest builds...")
 
-	// create an empty package.json file in our files dir.
-	// without this, node will walk up the file tree and search for a package.json in the
-	// parent folders. This might lead to issues if the user for example has ~/package.json
-	// with type: "module" in it
-	pkgJsonFile := path.Join(FilesDir, "package.json")
-	err := os.WriteFile(pkgJsonFile, []byte("{}"), 0644)
+	staged, err := stageBuild(rel)
 	if err != nil {
-		Log.Warn("Failed to create", pkgJsonFile, err)
-	}
-
-	var wg sync.WaitGroup
-
-	for _, ass := range ReleaseData.Assets {
-		if strings.HasPrefix(ass.Name, "patcher.js") ||
-			strings.HasPrefix(ass.Name, "preload.js") ||
-			strings.HasPrefix(ass.Name, "renderer.js") ||
-			strings.HasPrefix(ass.Name, "renderer.css") {
-			wg.Add(1)
-			ass := ass // Need to do this to not have the variable be overwritten halfway through
-			go func() {
-				defer wg.Done()
-				Log.Debug("Downloading file", ass.Name)
-
-				res, err := http.Get(ass.DownloadURL)
-				if err == nil && res.StatusCode >= 300 {
-					err = errors.New(res.Status)
-				}
-				if err != nil {
-					Log.Error("Failed to download", ass.Name+":", err)
-					retErr = err
-					return
-				}
-				outFile := path.Join(FilesDir, ass.Name)
-				out, err := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-				if err != nil {
-					Log.Error("Failed to create", outFile+":", err)
-					retErr = err
-					return
-				}
-				read, err := io.Copy(out, res.Body)
-				if err != nil {
-					Log.Error("Failed to download to", outFile+":", err)
-					retErr = err
-					return
-				}
-				contentLength := res.Header.Get("Content-Length")
-				expected := strconv.FormatInt(read, 10)
-				if expected != contentLength {
-					err = errors.New("Unexpected end of input. Content-Length was " + contentLength + ", but I only read " + expected)
-					Log.Error(err.Error())
-					retErr = err
-					return
-				}
-			}()
+		return err
+	}
+
+	hashes := make(map[string]string)
+	for _, ass := range rel.distAssets() {
+		if hashes[ass.Name], err = HashPath(path.Join(staged, ass.Name)); err != nil {
+			_ = os.RemoveAll(staged)
+			return err
 		}
 	}
 
-	wg.Wait()
+	if err = swapDist(staged); err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
 	Log.Debug("Done!")
-	_ = FixOwnership(FilesDir)
 
-	InstalledHash = LatestHash
-	return
+	InstalledHash = rel.hash
+	activeBuild := ""
+	if build, err := keepBuild(rel.source, rel.release.TagName, rel.hash, hashes); err != nil {
+		Log.Warn("Failed to keep a copy of the build to switch back to later:", err)
+	} else {
+		activeBuild = build.Id
+	}
+	if err = UpdateState(func(s *InstallerState) {
+		s.Source = rel.source.Id
+		s.DistHashes = hashes
+		s.ActiveBuild = activeBuild
+	}); err != nil {
+		Log.Warn("Failed to record that", rel.source.Name, "is installed:", err)
+	}
+	if _, err = PruneBuilds(KeepBuilds()); err != nil {
+		Log.Warn(err)
+	}
+	return nil
 }
@@ -0,0 +1,82 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// serveReleases serves a release named after the last path element of each request, with one asset per prefix
+// of vencordAssets plus one that isn't part of the dist
+func serveReleases(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		release := GithubRelease{Name: "Devbuild " + name, TagName: "devbuild"}
+		for _, asset := range append(vencordAssets, "README.md") {
+			release.Assets = append(release.Assets, GithubAsset{Name: asset, DownloadURL: "https://example.com/" + name + "/" + asset})
+		}
+		_ = json.NewEncoder(w).Encode(release)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLatestReleaseSnapshot(t *testing.T) {
+	useTempBaseDir(t)
+	srv := serveReleases(t)
+	a := &Source{Id: "a", Name: "A", ReleaseUrl: srv.URL + "/aaaaaaa", Assets: vencordAssets}
+	b := &Source{Id: "b", Name: "B", ReleaseUrl: srv.URL + "/bbbbbbb", Assets: vencordAssets}
+
+	oldSource, oldDone := SelectedSource, GithubDoneChan
+	SelectedSource, GithubDoneChan = a, make(chan bool, 1)
+	t.Cleanup(func() { SelectedSource, GithubDoneChan = oldSource, oldDone })
+
+	FetchLatestRelease()
+	if !<-GithubDoneChan {
+		t.Fatal(GithubError)
+	}
+	rel := latestRelease()
+
+	// Switching while the snapshot is used, like the GUI does during an install
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			SetSource(Ternary(i%2 == 0, b, a))
+			_ = IsDistOutdated()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		_ = latestRelease().distAssets()
+	}
+	wg.Wait()
+
+	if rel.source != a || rel.hash != "aaaaaaa" {
+		t.Errorf("snapshot changed to %s %s", rel.source.Name, rel.hash)
+	}
+	assets := rel.distAssets()
+	if len(assets) != len(vencordAssets) {
+		t.Errorf("dist assets are %v, want one per %v", assets, vencordAssets)
+	}
+	for _, ass := range assets {
+		if !strings.Contains(ass.DownloadURL, "/aaaaaaa/") {
+			t.Errorf("asset %s of the snapshot of A comes from %s", ass.Name, ass.DownloadURL)
+		}
+	}
+
+	// The last switch went back to A, and only its result is published
+	<-GithubDoneChan
+	if latest := latestRelease(); latest.source != a || latest.hash != "aaaaaaa" || latest.release.Assets[0].DownloadURL != "https://example.com/aaaaaaa/"+vencordAssets[0] {
+		t.Errorf("published %s %s, want A aaaaaaa", latest.source.Name, latest.hash)
+	}
+}
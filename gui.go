@@ -26,6 +26,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -45,8 +47,37 @@ var (
 	modalTitle   = "Oh No :("
 	modalMessage = "You should never see this"
 
-	acceptedOpenAsar   bool
-	showedUpdatePrompt bool
+	acceptedOpenAsar    bool
+	showedUpdatePrompt  bool
+	showedRecoverPrompt bool
+
+	pendingPlans []*Plan
+	shownBackups []*Backup
+	shownBuilds  []*Build
+	keepBuilds   int32
+
+	foreignModInstall *DiscordInstall
+	foreignModThen    func()
+
+	// shown by the Flatpak mode checkbox, which sets FlatpakMode once clicked
+	flatpakSandbox bool
+
+	runningInstall    *DiscordInstall
+	runningProcs      []DiscordProcess
+	runningThen       func()
+	waitingForDiscord bool
+	// paths of the installs that are running, checked in the background by refreshRunning
+	runningInstalls  map[string]bool
+	runningCheckedAt time.Time
+	runningLock      sync.Mutex
+	// functions to run on the UI thread, queued by background work
+	uiQueue = make(chan func(), 8)
+
+	sourceIdx    int32
+	customSource string
+	// releases of SelectedSource offered by the version selector, listed in the background
+	releases   []GithubRelease
+	releaseIdx int32
 
 	win *g.MasterWindow
 )
@@ -60,7 +91,14 @@ func init() {
 
 func main() {
 	InitGithubDownloader()
+	sourceIdx = int32(SliceIndex(Sources, SelectedSource))
+	if sourceIdx == -1 {
+		sourceIdx = int32(len(Sources))
+		customSource = SelectedSource.Id
+	}
+	pendingPlans = PendingPlans()
 	discords = FindDiscords()
+	fetchReleases()
 
 	customChoiceIdx = len(discords)
 
@@ -74,6 +112,15 @@ func main() {
 		g.Update()
 	}()
 
+	if runtime.GOOS == "linux" {
+		// redraw regularly so refreshRunning notices Discord starting and exiting
+		go func() {
+			for range time.Tick(2 * time.Second) {
+				g.Update()
+			}
+		}()
+	}
+
 	win = g.NewMasterWindow("VencordJP インストーラー", 1200, 800, 0)
 
 	g.SetDefaultFont("YuGothM.ttc", 12)
@@ -120,22 +167,237 @@ func InstallLatestBuilds() (err error) {
 	}
 
 	err = installLatestBuilds()
+	if showVerifyError(err) {
+		return
+	}
 	if err != nil {
 		ShowModal("おっと。エラーが発生したようです。", "GitHubから最新のVencordJPビルドをダウンロードできませんでした。詳細:\n"+err.Error())
 	}
 	return
 }
 
+// stateLabels are the labels of install states shown next to installs, see InstallState.Label
+var stateLabels = map[InstallState]string{
+	StateVanilla:         "未変更",
+	StatePatched:         "パッチ済み",
+	StateStalePatch:      "アップデートでパッチ解除",
+	StateForeignMod:      "他のMod",
+	StateOpenAsar:        "OpenAsar",
+	StateOpenAsarPatched: "OpenAsar + パッチ済み",
+	StateReadOnlyStore:   "読み取り専用ストア",
+	StateBroken:          "破損",
+}
+
+// handleAuto does whatever the chosen install needs based on its state
+func handleAuto() {
+	choice := getChosenInstall()
+	if choice == nil {
+		return
+	}
+	action, err := choice.AutoAction()
+	if err != nil {
+		handleErr(choice, err, "fix")
+		return
+	}
+	if action == "" {
+		ShowModal("何もする必要はありません", "Vencordはインストール済みで、最新の状態です。")
+		return
+	}
+	handlePatch()
+}
+
 func handlePatch() {
 	choice := getChosenInstall()
-	if choice != nil {
+	if choice != nil && !checkRunning(choice, handlePatch) && !checkForeignMod(choice, choice.Patch) {
 		choice.Patch()
 	}
 }
 
+// checkRunning asks whether to close di or wait for it if it is running, then runs then.
+// Returns false if it isn't running
+func checkRunning(di *DiscordInstall, then func()) bool {
+	procs := di.RunningProcesses()
+	if len(procs) == 0 {
+		return false
+	}
+	runningInstall, runningProcs, runningThen = di, procs, then
+	g.OpenPopup("#discord-running")
+	return true
+}
+
+// whenStopped runs stop in the background, then continues with runningThen on the UI thread
+func whenStopped(stop func() error) {
+	di, then := runningInstall, runningThen
+	waitingForDiscord = true
+	go func() {
+		err := stop()
+		uiQueue <- func() {
+			waitingForDiscord = false
+			if err != nil {
+				handleErr(di, err, "close Discord for")
+			} else {
+				then()
+			}
+		}
+		g.Update()
+	}()
+}
+
+func isRunning(di *DiscordInstall) bool {
+	runningLock.Lock()
+	defer runningLock.Unlock()
+	return runningInstalls[di.path]
+}
+
+// refreshRunning checks in the background which installs are running, at most every 2 seconds
+func refreshRunning() {
+	if runtime.GOOS != "linux" || time.Since(runningCheckedAt) < 2*time.Second {
+		return
+	}
+	runningCheckedAt = time.Now()
+
+	installs := SliceMap(discords, func(d any) *DiscordInstall { return d.(*DiscordInstall) })
+	go func() {
+		running := make(map[string]bool)
+		for _, di := range installs {
+			if len(di.RunningProcesses()) > 0 {
+				running[di.path] = true
+			}
+		}
+
+		runningLock.Lock()
+		changed := len(running) != len(runningInstalls)
+		for p := range running {
+			changed = changed || !runningInstalls[p]
+		}
+		runningInstalls = running
+		runningLock.Unlock()
+
+		if changed {
+			g.Update()
+		}
+	}()
+}
+
+func RunningModal() g.Widget {
+	title, description := "", ""
+	if di := runningInstall; di != nil {
+		title = "Discordが実行中です"
+		description = di.path + "のDiscordが実行中です（PID " + describeProcesses(runningProcs) + "）。\n" +
+			"実行中にパッチを適用すると、再起動するまで変更が反映されません。\n\n" +
+			"Discordを終了して続行しますか？"
+	}
+
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#discord-running").
+				Flags(g.WindowFlagsNoTitleBar | g.WindowFlagsAlwaysAutoResize).
+				Layout(
+					g.Align(g.AlignCenter).To(
+						g.Style().SetFontSize(30).To(
+							g.Label(title),
+						),
+						g.Style().SetFontSize(20).To(
+							g.Label(description),
+						),
+						g.Dummy(0, 20),
+						g.Row(
+							g.Button("終了して続行").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+									di, procs := runningInstall, runningProcs
+									whenStopped(func() error { return di.StopProcesses(procs) })
+								}).
+								Size(150, 30),
+							g.Button("終了を待つ").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+									procs := runningProcs
+									whenStopped(func() error {
+										WaitForProcesses(procs)
+										return nil
+									})
+								}).
+								Size(150, 30),
+							g.Button("キャンセル").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+								}).
+								Size(100, 30),
+						),
+					),
+				),
+		)
+}
+
+// checkForeignMod asks whether to remove another client mod from di if there is one, then runs then.
+// Returns false if there is no other mod
+func checkForeignMod(di *DiscordInstall, then func()) bool {
+	if di.foreignMod == nil {
+		return false
+	}
+	foreignModInstall, foreignModThen = di, then
+	g.OpenPopup("#foreign-mod")
+	return true
+}
+
+func handleRemoveForeignMod() {
+	di := foreignModInstall
+	name := di.foreignMod.Name
+	if err := di.RemoveForeignMod(); err != nil {
+		handleErr(di, err, "remove "+name+" from")
+		return
+	}
+	foreignModThen()
+}
+
+func ForeignModModal() g.Widget {
+	title, description := "", ""
+	if di := foreignModInstall; di != nil && di.foreignMod != nil {
+		title = di.foreignMod.Name + "が見つかりました"
+		description = di.path + "は" + di.foreignMod.String() + "によって変更されています。\n" +
+			"その上にVencordをインストールすると、両方とも正しく動作しなくなります。\n\n" +
+			di.foreignMod.Name + "を削除してから続行しますか？"
+	}
+
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#foreign-mod").
+				Flags(g.WindowFlagsNoTitleBar | g.WindowFlagsAlwaysAutoResize).
+				Layout(
+					g.Align(g.AlignCenter).To(
+						g.Style().SetFontSize(30).To(
+							g.Label(title),
+						),
+						g.Style().SetFontSize(20).To(
+							g.Label(description),
+						),
+						g.Dummy(0, 20),
+						g.Row(
+							g.Button("削除して続行").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+									handleRemoveForeignMod()
+								}).
+								Size(150, 30),
+							g.Button("キャンセル").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+								}).
+								Size(100, 30),
+						),
+					),
+				),
+		)
+}
+
 func handleUnpatch() {
 	choice := getChosenInstall()
-	if choice != nil {
+	if choice != nil && !checkRunning(choice, handleUnpatch) {
 		choice.Unpatch()
 	}
 }
@@ -151,26 +413,330 @@ func handleOpenAsar() {
 
 func handleOpenAsarConfirmed() {
 	choice := getChosenInstall()
-	if choice != nil {
+	if choice != nil && !checkRunning(choice, handleOpenAsarConfirmed) {
 		if choice.IsOpenAsar() {
 			if err := choice.UninstallOpenAsar(); err != nil {
 				handleErr(choice, err, "uninstall OpenAsar from")
 			} else {
-				g.OpenPopup("#openasar-unpatched")
+				showSuccess(choice, "#openasar-unpatched", "OpenAsarのアンインストールに成功しました")
 				g.Update()
 			}
-		} else {
+		} else if !checkForeignMod(choice, handleOpenAsarConfirmed) {
 			if err := choice.InstallOpenAsar(); err != nil {
 				handleErr(choice, err, "install OpenAsar on")
 			} else {
-				g.OpenPopup("#openasar-patched")
+				showSuccess(choice, "#openasar-patched", "OpenAsarのインストールに成功しました")
 				g.Update()
 			}
 		}
 	}
 }
 
+func handleBackups() {
+	choice := getChosenInstall()
+	if choice != nil {
+		shownBackups = BackupsFor(choice.Paths()...)
+		g.OpenPopup("#backups")
+	}
+}
+
+func handleRestoreBackup(b *Backup) {
+	choice := getChosenInstall()
+	if choice == nil {
+		return
+	}
+	if err := choice.RestoreBackup(b); err != nil {
+		handleErr(choice, err, "restore a backup to")
+	} else if relaunched, err := choice.Relaunch(); err != nil || relaunched {
+		showRelaunched(choice, "復元に成功しました", err)
+	} else {
+		ShowModal("復元に成功しました", "DiscordをバックアップからDiscord "+b.Version+"の元の状態に戻しました。\n"+
+			"Discordがまだ開いている場合は、完全に閉じてから再起動してください。")
+	}
+}
+
+func handleBuilds() {
+	shownBuilds = ListBuilds()
+	keepBuilds = int32(KeepBuilds())
+	g.OpenPopup("#builds")
+}
+
+func handleUseBuild(b *Build) {
+	if err := UseBuild(b); err != nil {
+		ShowModal("ビルドの切り替えに失敗しました", err.Error())
+		return
+	}
+	if err := SyncSandboxDists(SliceMap(discords, func(d any) *DiscordInstall { return d.(*DiscordInstall) })); err != nil {
+		ShowModal("ビルドの切り替えに失敗しました", "Flatpakのサンドボックス内のコピーを更新できませんでした:\n"+err.Error())
+		return
+	}
+	ShowModal("ビルドを切り替えました", "Vencordのビルド "+b.Tag+" ("+b.Hash+") を使用しています。\n"+
+		"Discordがまだ開いている場合は、完全に閉じてから再起動してください。")
+}
+
+func handleRollback() {
+	b, err := PreviousBuild()
+	if err != nil {
+		ShowModal("ロールバックできません", err.Error())
+		return
+	}
+	handleUseBuild(b)
+}
+
+func handleKeepBuilds() {
+	if keepBuilds < 1 {
+		keepBuilds = 1
+	}
+	if err := UpdateState(func(s *InstallerState) {
+		s.KeepBuilds = int(keepBuilds)
+	}); err != nil {
+		Log.Warn("Failed to save how many builds to keep:", err)
+	}
+}
+
+func handleSourceChange() {
+	if int(sourceIdx) < len(Sources) {
+		switchSource(Sources[sourceIdx])
+	}
+}
+
+func handleCustomSource() {
+	src, err := ParseSource(strings.TrimSpace(customSource))
+	if err != nil {
+		ShowModal("無効なダウンロード元", err.Error())
+		return
+	}
+	switchSource(src)
+}
+
+func switchSource(src *Source) {
+	SetSource(src)
+	fetchReleases()
+	go func() {
+		<-GithubDoneChan
+		g.Update()
+	}()
+}
+
+// fetchReleases lists the releases of SelectedSource for the version selector
+func fetchReleases() {
+	releases = nil
+	if IsDevInstall {
+		return
+	}
+	src := SelectedSource
+	go func() {
+		list, err := ListReleases(src)
+		if err != nil {
+			Log.Warn("Failed to list the releases of", src.Name+":", err)
+		}
+		uiQueue <- func() {
+			if src == SelectedSource {
+				releases = list
+			}
+		}
+		g.Update()
+	}()
+}
+
+func handleVersionChange(version string) {
+	// Only pinned once installing it worked
+	RequestVersion(SelectedSource, version)
+	RefetchRelease()
+	go func() {
+		<-GithubDoneChan
+		g.Update()
+	}()
+}
+
+func renderVersionSelector() g.Widget {
+	pin := PinnedVersion(SelectedSource)
+	names := []string{"最新"}
+	versions := []string{LatestVersion}
+	releaseIdx = 0
+	for _, r := range releases {
+		if pin != "" && releaseIdx == 0 && r.Matches(pin) {
+			releaseIdx = int32(len(names))
+		}
+		names = append(names, r.TagName+" - "+r.Name+" ("+r.PublishedAt.Format("2006-01-02")+")")
+		versions = append(versions, r.Version())
+	}
+	if pin != "" && releaseIdx == 0 {
+		// not among the listed releases, like an older devbuild that is only kept locally
+		releaseIdx = int32(len(names))
+		names = append(names, "固定: "+pin)
+		versions = append(versions, pin)
+	}
+
+	return g.Row(
+		g.Label("バージョン: "),
+		g.Combo("##version", names[releaseIdx], names, &releaseIdx).
+			Size(400).
+			OnChange(func() {
+				handleVersionChange(versions[releaseIdx])
+			}),
+		Tooltip("インストールするVencordのリリースです。固定したバージョンは修復しても更新されません。"),
+	)
+}
+
+func renderSourceSelector() g.Widget {
+	names := append(SliceMap(Sources, func(src *Source) string { return src.Name }), "カスタムフォーク")
+	return g.Column(
+		g.Row(
+			g.Label("ダウンロード元: "),
+			g.Combo("##source", names[sourceIdx], names, &sourceIdx).
+				Size(250).
+				OnChange(handleSourceChange),
+			Tooltip("インストールと修復でVencordをダウンロードする場所です。最後にインストールしたものが記憶されます。"),
+		),
+		&CondWidget{int(sourceIdx) == len(Sources), func() g.Widget {
+			return g.Row(
+				g.InputText(&customSource).Hint("owner/repo またはリリースのURL").Size(400),
+				g.Style().
+					SetColor(g.StyleColorButton, DiscordBlue).
+					SetStyle(g.StyleVarFramePadding, 4, 4).
+					To(
+						g.Button("適用").OnClick(handleCustomSource),
+					),
+			)
+		}, nil},
+	)
+}
+
+func BackupsModal() g.Widget {
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#backups").
+				Flags(g.WindowFlagsNoTitleBar | g.WindowFlagsAlwaysAutoResize).
+				Layout(
+					g.Align(g.AlignCenter).To(
+						g.Style().SetFontSize(30).To(
+							g.Label("バックアップ"),
+						),
+						g.Style().SetFontSize(20).To(
+							&CondWidget{len(shownBackups) == 0, func() g.Widget {
+								return g.Label("このインストールのバックアップはまだありません。\n初めてパッチを適用するときに自動的に作成されます。")
+							}, nil},
+							g.RangeBuilder("Backups", SliceMap(shownBackups, func(b *Backup) any { return b }), func(i int, v any) g.Widget {
+								b := v.(*Backup)
+								return g.Row(
+									g.Label(b.String()),
+									g.Button("復元##"+b.Id).
+										OnClick(func() {
+											g.CloseCurrentPopup()
+											handleRestoreBackup(b)
+										}),
+								)
+							}),
+						),
+						g.Dummy(0, 20),
+						g.Row(
+							g.Button("古いバックアップを削除").
+								OnClick(func() {
+									if _, err := PruneBackups(1); err != nil {
+										g.CloseCurrentPopup()
+										ShowModal("バックアップの削除に失敗しました", err.Error())
+									}
+									shownBackups = BackupsFor(shownBackupsInstall())
+								}).
+								Size(250, 30),
+							g.Button("閉じる").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+								}).
+								Size(100, 30),
+						),
+					),
+				),
+		)
+}
+
+func BuildsModal() g.Widget {
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#builds").
+				Flags(g.WindowFlagsNoTitleBar | g.WindowFlagsAlwaysAutoResize).
+				Layout(
+					g.Align(g.AlignCenter).To(
+						g.Style().SetFontSize(30).To(
+							g.Label("Vencordのビルド"),
+						),
+						g.Style().SetFontSize(20).To(
+							&CondWidget{len(shownBuilds) == 0, func() g.Widget {
+								return g.Label("保存されたビルドはまだありません。\nVencordをインストールするたびに自動的に保存されます。")
+							}, nil},
+							g.RangeBuilder("Builds", SliceMap(shownBuilds, func(b *Build) any { return b }), func(i int, v any) g.Widget {
+								b := v.(*Build)
+								return g.Row(
+									g.Label(b.String()+Ternary(b.IsActive(), " [使用中]", "")),
+									g.Style().
+										SetDisabled(b.IsActive()).
+										To(
+											g.Button("使用##"+b.Id).
+												OnClick(func() {
+													g.CloseCurrentPopup()
+													handleUseBuild(b)
+												}),
+										),
+								)
+							}),
+							g.Dummy(0, 10),
+							g.Row(
+								g.Label("保存するビルドの数: "),
+								g.InputInt(&keepBuilds).Size(100).OnChange(handleKeepBuilds),
+								Tooltip("新しいビルドをインストールすると、これより古いビルドは削除されます。"),
+							),
+						),
+						g.Dummy(0, 20),
+						g.Row(
+							g.Button("前のビルドに戻す").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+									handleRollback()
+								}).
+								Size(250, 30),
+							g.Button("閉じる").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+								}).
+								Size(100, 30),
+						),
+					),
+				),
+		)
+}
+
+func shownBackupsInstall() string {
+	if len(shownBackups) == 0 {
+		return ""
+	}
+	return shownBackups[0].Install
+}
+
+// showVerifyError explains a download that failed verification. Returns false for other errors
+func showVerifyError(err error) bool {
+	var verifyErr *AssetVerifyError
+	if !errors.As(err, &verifyErr) {
+		return false
+	}
+	ShowModal("ダウンロードの検証に失敗しました", "ダウンロードした"+verifyErr.Asset+"が正しくないため、インストールを中止しました。\n"+
+		"詳細: "+verifyErr.Problem+"\n\n"+
+		"以前のビルドはそのまま残っています。ダウンロードしたファイルは確認用にここに保存されています:\n"+verifyErr.Staged)
+	return true
+}
+
 func handleErr(di *DiscordInstall, err error, action string) {
+	// the failed change was rolled back, so a Discord closed for it can run again
+	if _, err := di.Relaunch(); err != nil {
+		Log.Warn("Failed to restart Discord at", di.path+":", err)
+	}
+	if showVerifyError(err) {
+		return
+	}
 	if errors.Is(err, os.ErrPermission) {
 		switch runtime.GOOS {
 		case "windows":
@@ -187,6 +753,13 @@ func handleErr(di *DiscordInstall, err error, action string) {
 	ShowModal("Failed to "+action+" this Install", err.Error())
 }
 
+// HandleRunningDiscord is called by PreparePatch. checkRunning already asked what to do, so Discord was
+// started again in the meantime. Don't ask again, it only has to be restarted
+func HandleRunningDiscord(_ *DiscordInstall, procs []DiscordProcess) error {
+	Log.Warn("Discord is running (" + describeProcesses(procs) + "). Restart it to load the changes")
+	return nil
+}
+
 func HandleScuffedInstall() {
 	g.OpenPopup("#scuffed-install")
 }
@@ -198,7 +771,7 @@ func (di *DiscordInstall) Patch() {
 	if err := di.patch(); err != nil {
 		handleErr(di, err, "patch")
 	} else {
-		g.OpenPopup("#patched")
+		showSuccess(di, "#patched", "パッチ適用に成功しました")
 	}
 }
 
@@ -206,7 +779,26 @@ func (di *DiscordInstall) Unpatch() {
 	if err := di.unpatch(); err != nil {
 		handleErr(di, err, "unpatch")
 	} else {
-		g.OpenPopup("#unpatched")
+		showSuccess(di, "#unpatched", "パッチ解除に成功しました")
+	}
+}
+
+// showSuccess opens popup, unless Discord was closed for the change and is restarted, which popup would
+// tell the user to do
+func showSuccess(di *DiscordInstall, popup, title string) {
+	if relaunched, err := di.Relaunch(); err != nil || relaunched {
+		showRelaunched(di, title, err)
+	} else {
+		g.OpenPopup(popup)
+	}
+}
+
+func showRelaunched(di *DiscordInstall, title string, err error) {
+	if err != nil {
+		Log.Warn("Failed to restart Discord at", di.path+":", err)
+		ShowModal(title, "Discordを再起動できませんでした: "+err.Error()+"\nDiscordを手動で起動してください。")
+	} else {
+		ShowModal(title, "Discordを再起動しました。変更はすでに読み込まれています！")
 	}
 }
 
@@ -410,6 +1002,68 @@ func UpdateModal() g.Widget {
 		)
 }
 
+func handleRecover(forward bool) {
+	var errs []string
+	for _, p := range pendingPlans {
+		if err := p.Recover(forward); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	pendingPlans = PendingPlans()
+	discords = FindDiscords()
+	customChoiceIdx = len(discords)
+	radioIdx = 0
+
+	if len(errs) > 0 {
+		ShowModal("復旧に失敗しました", strings.Join(errs, "\n"))
+	}
+}
+
+func RecoverModal() g.Widget {
+	description := "前回の操作が途中で中断されました（クラッシュや電源断など）。\n" +
+		"このままではDiscordが起動しない可能性があります。完了させるか、元に戻してください。\n\n"
+	for _, p := range pendingPlans {
+		description += p.String() + "\n"
+	}
+
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#recover-prompt").
+				Flags(g.WindowFlagsNoTitleBar | g.WindowFlagsAlwaysAutoResize).
+				Layout(
+					g.Align(g.AlignCenter).To(
+						g.Style().SetFontSize(30).To(
+							g.Label("中断された操作"),
+						),
+						g.Style().SetFontSize(20).To(
+							g.Label(description),
+						),
+						g.Row(
+							g.Button("完了させる").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+									handleRecover(true)
+								}).
+								Size(150, 30),
+							g.Button("元に戻す").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+									handleRecover(false)
+								}).
+								Size(150, 30),
+							g.Button("後で").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+								}).
+								Size(100, 30),
+						),
+					),
+				),
+		)
+}
+
 func ShowModal(title, desc string) {
 	modalTitle = title
 	modalMessage = desc
@@ -421,6 +1075,7 @@ func renderInstaller() g.Widget {
 	candidates := makeAutoComplete()
 	wi, _ := win.GetSize()
 	w := float32(wi) - 96
+	_, githubErr := LatestReleaseStatus()
 
 	var currentDiscord *DiscordInstall
 	if radioIdx != customChoiceIdx {
@@ -428,7 +1083,20 @@ func renderInstaller() g.Widget {
 	}
 	var isOpenAsar = currentDiscord != nil && currentDiscord.IsOpenAsar()
 
-	if CanUpdateSelf() && !showedUpdatePrompt {
+	refreshRunning()
+	if FlatpakMode == "" && currentDiscord != nil {
+		flatpakSandbox = currentDiscord.status.FlatpakMode == FlatpakModeSandbox
+	}
+	select {
+	case f := <-uiQueue:
+		f()
+	default:
+	}
+
+	if len(pendingPlans) > 0 && !showedRecoverPrompt {
+		showedRecoverPrompt = true
+		g.OpenPopup("#recover-prompt")
+	} else if CanUpdateSelf() && !showedUpdatePrompt {
 		showedUpdatePrompt = true
 		g.OpenPopup("#update-prompt")
 	}
@@ -466,8 +1134,16 @@ func renderInstaller() g.Widget {
 				d := v.(*DiscordInstall)
 				//goland:noinspection GoDeprecation
 				text := strings.Title(d.branch) + " - " + d.path
-				if d.isPatched {
-					text += " [パッチ済み]"
+				if isRunning(d) {
+					text += " [実行中]"
+				}
+				if len(d.aliases) > 0 {
+					text += " (別名: " + strings.Join(d.aliases, ", ") + ")"
+				}
+				if d.foreignMod != nil {
+					text += " [" + d.foreignMod.Name + "]"
+				} else if d.status.State != StateVanilla {
+					text += " [" + stateLabels[d.status.State] + "]"
 				}
 				return g.RadioButton(text, radioIdx == i).
 					OnChange(makeRadioOnChange(i))
@@ -477,6 +1153,28 @@ func renderInstaller() g.Widget {
 				OnChange(makeRadioOnChange(customChoiceIdx)),
 		),
 
+		&CondWidget{waitingForDiscord, func() g.Widget {
+			return g.Label("Discordの終了を待っています...")
+		}, nil},
+
+		&CondWidget{currentDiscord != nil && len(currentDiscord.status.Reasons) > 0, func() g.Widget {
+			return g.Label("状態: " + stateLabels[currentDiscord.status.State] + "\n" + strings.Join(currentDiscord.status.Reasons, "\n"))
+		}, nil},
+
+		&CondWidget{currentDiscord != nil && currentDiscord.isFlatpak, func() g.Widget {
+			mode := Ternary(currentDiscord.status.FlatpakMode == FlatpakModeSandbox, "サンドボックス内のコピー", "ファイルシステムの上書き (flatpak override)")
+			return g.Row(
+				g.Checkbox("Vencordをサンドボックス内にコピーする", &flatpakSandbox).
+					OnChange(func() {
+						FlatpakMode = Ternary(flatpakSandbox, FlatpakModeSandbox, FlatpakModeOverride)
+					}),
+				Tooltip("Flatpakにフォルダへのアクセスを許可する代わりに、VencordをDiscordのFlatpakのデータフォルダにコピーします。"),
+				&CondWidget{currentDiscord.status.FlatpakMode != "", func() g.Widget {
+					return g.Label("現在のモード: " + mode)
+				}, nil},
+			)
+		}, nil},
+
 		g.Dummy(0, 5),
 		g.Style().
 			SetStyle(g.StyleVarFramePadding, 16, 16).
@@ -530,7 +1228,7 @@ func renderInstaller() g.Widget {
 			g.Row(
 				g.Style().
 					SetColor(g.StyleColorButton, DiscordGreen).
-					SetDisabled(GithubError != nil).
+					SetDisabled(githubErr != nil).
 					To(
 						g.Button("インストール").
 							OnClick(handlePatch).
@@ -539,7 +1237,7 @@ func renderInstaller() g.Widget {
 					),
 				g.Style().
 					SetColor(g.StyleColorButton, DiscordBlue).
-					SetDisabled(GithubError != nil).
+					SetDisabled(githubErr != nil).
 					To(
 						g.Button("再インストール / 修復").
 							OnClick(func() {
@@ -572,6 +1270,41 @@ func renderInstaller() g.Widget {
 						Tooltip("OpenAsarを管理します。"),
 					),
 			),
+			g.Dummy(0, 5),
+			g.Row(
+				g.Style().
+					SetColor(g.StyleColorButton, DiscordBlue).
+					To(
+						g.Button("バックアップ").
+							OnClick(handleBackups).
+							Size((w-40)/4, 30),
+						Tooltip("パッチ前に保存された元のDiscordファイルを表示・復元します。"),
+					),
+				g.Style().
+					SetColor(g.StyleColorButton, DiscordBlue).
+					SetDisabled(IsDevInstall).
+					To(
+						g.Button("ビルド").
+							OnClick(handleBuilds).
+							Size((w-40)/4, 30),
+						Tooltip("以前にインストールしたVencordのビルドに、ダウンロードせずに切り替えます。"),
+					),
+				g.Style().
+					SetColor(g.StyleColorButton, DiscordGreen).
+					SetDisabled(githubErr != nil || currentDiscord == nil).
+					To(
+						g.Button("自動で修復").
+							OnClick(handleAuto).
+							Size((w-40)/4, 30),
+						Tooltip("インストールの状態に応じて、インストール・アップデート・修復のうち必要なものを行います。"),
+					),
+				&CondWidget{runtime.GOOS == "linux", func() g.Widget {
+					return g.Row(
+						g.Checkbox("完了後にDiscordを再起動する", &RelaunchDiscord),
+						Tooltip("変更のためにDiscordを終了した場合、完了後に同じ方法でもう一度起動します。"),
+					)
+				}, nil},
+			),
 		),
 
 		InfoModal("#patched", "パッチ適用に成功しました", "Discordがまだ開いている場合は、完全に閉じてください。\n"+
@@ -593,6 +1326,11 @@ func renderInstaller() g.Widget {
 		InfoModal("#modal"+strconv.Itoa(modalId), modalTitle, modalMessage),
 
 		UpdateModal(),
+		RecoverModal(),
+		BackupsModal(),
+		BuildsModal(),
+		ForeignModModal(),
+		RunningModal(),
 	}
 
 	return layout
@@ -618,6 +1356,7 @@ func renderErrorCard(col color.Color, message string, height float32) g.Widget {
 }
 
 func loop() {
+	latestHash, githubErr := LatestReleaseStatus()
 	g.PushWindowPadding(48, 48)
 
 	g.SingleWindow().
@@ -658,16 +1397,21 @@ func loop() {
 				}, nil},
 				g.Dummy(0, 10),
 				g.Label("インストーラーバージョン: "+buildinfo.InstallerTag+" ("+buildinfo.InstallerGitHash+")"+Ternary(IsSelfOutdated, " - 古い", "")),
-				g.Label("ローカルのVencordJPバージョン: "+InstalledHash),
+				renderSourceSelector(),
+				&CondWidget{!IsDevInstall, renderVersionSelector, nil},
+				g.Label("ローカルの"+InstalledSource().Name+"バージョン: "+InstalledHash),
 				&CondWidget{
-					GithubError == nil,
+					githubErr == nil,
 					func() g.Widget {
 						if IsDevInstall {
 							return g.Label("開発モードの場合、Vencordは更新されません。")
 						}
-						return g.Label("最新のVencordJPバージョン: " + LatestHash)
+						if pin := PinnedVersion(SelectedSource); pin != "" {
+							return g.Label("固定された" + SelectedSource.Name + "バージョン: " + pin + " (" + latestHash + ")")
+						}
+						return g.Label("最新の" + SelectedSource.Name + "バージョン: " + latestHash)
 					}, func() g.Widget {
-						return renderErrorCard(DiscordRed, "GitHubから情報を取得できませんでした。詳細: "+GithubError.Error(), 40)
+						return renderErrorCard(DiscordRed, "GitHubから情報を取得できませんでした。詳細: "+githubErr.Error(), 40)
 					},
 				},
 			),
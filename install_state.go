@@ -0,0 +1,204 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	path "path/filepath"
+	"vencordinstaller/asar"
+)
+
+type InstallState string
+
+const (
+	StateVanilla InstallState = "vanilla"
+	StatePatched InstallState = "patched"
+	// StateStalePatch means Discord updated and replaced our stub, so it runs without Vencord again
+	StateStalePatch      InstallState = "stale-patch"
+	StateForeignMod      InstallState = "foreign-mod"
+	StateOpenAsar        InstallState = "openasar"
+	StateOpenAsarPatched InstallState = "openasar+patched"
+	// StateReadOnlyStore means the install is in the Nix or Guix store, so we can't touch it
+	StateReadOnlyStore InstallState = "read-only-store"
+	// StateBroken is anything half patched, see InstallStatus.Reasons for what exactly
+	StateBroken InstallState = "broken"
+)
+
+// InstallStatus is the state of an install and why we think it is in that state
+type InstallStatus struct {
+	State   InstallState `json:"state"`
+	Reasons []string     `json:"reasons,omitempty"`
+	// how a patched Flatpak reads Vencord's files, see FlatpakModeOverride and FlatpakModeSandbox
+	FlatpakMode string `json:"flatpakMode,omitempty"`
+	// the action that fixes a broken install, "" if it can't be fixed automatically
+	fix string
+	// the reason fix is about
+	problem string
+}
+
+func (s *InstallStatus) reason(reason string) {
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// broken marks the install as broken for reason. fix is the action that fixes it, if any
+func (s *InstallStatus) broken(reason, fix string) {
+	s.reason(reason)
+	// a problem that needs recovering or can't be fixed at all wins over one that installing fixes
+	if s.State != StateBroken || s.fix == "install" {
+		s.fix, s.problem = fix, reason
+	}
+	s.State = StateBroken
+}
+
+// Label is the state in a few words, for lists
+func (s InstallState) Label() string {
+	switch s {
+	case StateStalePatch:
+		return "stale patch"
+	case StateOpenAsarPatched:
+		return "OpenAsar + patched"
+	case StateOpenAsar:
+		return "OpenAsar"
+	case StateForeignMod:
+		return "other mod"
+	case StateReadOnlyStore:
+		return "read-only store"
+	default:
+		return string(s)
+	}
+}
+
+// IsPatched reports whether di has our stub in place with the original app.asar next to it
+func (di *DiscordInstall) IsPatched() bool {
+	return di.status.State == StatePatched || di.status.State == StateOpenAsarPatched
+}
+
+// refreshState inspects the files of di again. Call it whenever they were changed
+func (di *DiscordInstall) refreshState() {
+	di.foreignMod = di.DetectForeignMod()
+	di.status = di.computeStatus()
+	if di.isFlatpak && di.store == "" {
+		di.checkFlatpak(&di.status)
+	}
+	Log.Debug("State of", di.path+":", di.status.State, di.status.Reasons)
+}
+
+func (di *DiscordInstall) computeStatus() InstallStatus {
+	var s InstallStatus
+	appAsar := di.appAsar()
+	_appAsar := di.originalAsar()
+
+	if di.store != "" {
+		s.State = StateReadOnlyStore
+		s.reason("in the " + Ternary(di.store == "nix", "Nix", "Guix") + " store, which can't be patched in place. Use the store command to patch a writable copy")
+		return s
+	}
+
+	if plan := FindPendingPlan(di.Paths()...); plan != nil {
+		s.broken("the installer was interrupted during "+plan.Action+" at "+plan.Created.Format("2006-01-02 15:04"), "recover")
+	}
+
+	if di.foreignMod != nil {
+		if s.State != StateBroken {
+			s.State = StateForeignMod
+		}
+		s.reason("modified by " + di.foreignMod.String())
+		return s
+	}
+
+	if !ExistsFile(appAsar) {
+		s.broken(appAsar+" is missing", "")
+		return s
+	}
+	a, err := asar.Open(appAsar)
+	if err != nil {
+		s.broken(appAsar+" is not a valid asar: "+err.Error(), "")
+		return s
+	}
+	_ = a.Close()
+
+	if target, _ := ReadStubTarget(appAsar); target != "" {
+		// DetectForeignMod already made sure it is our stub
+		if !ExistsFile(_appAsar) {
+			s.broken(appAsar+" is our stub, but Discord's original "+path.Base(_appAsar)+" is gone", "")
+			return s
+		}
+		if IsStubAsar(_appAsar) {
+			s.broken(_appAsar+" is a stub as well, Discord's original app.asar is gone", "")
+			return s
+		}
+		if di.isSystemElectron && !ExistsFile(_appAsar+".unpacked") {
+			s.broken(_appAsar+".unpacked is missing", Ternary(ExistsFile(appAsar+".unpacked"), "install", ""))
+		}
+		if patcher := di.patcherPath(); target != patcher {
+			s.reason(appAsar + " requires " + target + " instead of " + patcher)
+		}
+
+		tmp := appAsar + ".tmp"
+		if ExistsFile(tmp) {
+			if IsStubAsar(tmp) {
+				s.broken("leftover "+tmp+" from an unfinished uninstall or repair", "install")
+			} else {
+				s.broken(tmp+" is not a stub, it might be the only copy of some Discord files", "")
+			}
+		}
+
+		if s.State != StateBroken {
+			s.State = Ternary(isOpenAsarFile(_appAsar), StateOpenAsarPatched, StatePatched)
+		}
+		return s
+	}
+
+	if s.State == StateBroken {
+		return s
+	}
+	switch {
+	case isOpenAsarFile(appAsar):
+		s.State = StateOpenAsar
+	case ExistsFile(_appAsar):
+		s.State = StateStalePatch
+		s.reason("Discord updated and replaced the stub app.asar, " + _appAsar + " is the previous version")
+	default:
+		s.State = StateVanilla
+	}
+	return s
+}
+
+// AutoAction picks what to do with di based on its state: install, migrate (remove the other mod, then install)
+// or "" if there is nothing to do. Returns an error if di is broken in a way that can't be fixed automatically
+func (di *DiscordInstall) AutoAction() (string, error) {
+	s := di.status
+	switch s.State {
+	case StateVanilla, StateStalePatch, StateOpenAsar:
+		return "install", nil
+	case StateForeignMod:
+		return "migrate", nil
+	case StateReadOnlyStore:
+		return "", di.ErrReadOnlyStore()
+	case StatePatched, StateOpenAsarPatched:
+		stub, _ := ReadStubTarget(di.appAsar())
+		if stub != di.patcherPath() || (!IsDevInstall && IsDistOutdated()) {
+			return "install", nil
+		}
+		return "", nil
+	}
+
+	switch s.fix {
+	case "install":
+		return "install", nil
+	case "recover":
+		return "", errors.New(s.problem + ". Finish or undo that first with recover")
+	}
+	return "", errors.New(s.problem + ". Restore a backup or reinstall Discord")
+}
+
+// ErrReadOnlyStore explains why we can't patch an install in the Nix or Guix store and what to do instead
+func (di *DiscordInstall) ErrReadOnlyStore() error {
+	return errors.New(di.path + " is in the " + Ternary(di.store == "nix", "Nix", "Guix") + " store, which is read-only and can't be patched in place.\n" +
+		"Either patch a writable copy of it with the store copy command" +
+		Ternary(di.store == "nix", ", or let Nix install Vencord (see the store snippet command)", ""))
+}
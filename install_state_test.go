@@ -0,0 +1,122 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"testing"
+	"vencordinstaller/asar"
+)
+
+func TestComputeStatus(t *testing.T) {
+	vanilla := map[string][]byte{"package.json": []byte(`{"name":"discord","main":"app_bootstrap/index.js"}`), "app_bootstrap/index.js": []byte("")}
+	openAsar := map[string][]byte{"package.json": []byte(`{"name":"discord","main":"index.js"}`), "index.js": []byte(""), "asarUpdate.js": []byte("")}
+	stub := func(target string) []byte {
+		b, err := BuildAppAsar(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name string
+		// asars and other files in the resources folder
+		asars   map[string]map[string][]byte
+		files   map[string][]byte
+		store   string
+		pending bool
+		want    InstallState
+		// what AutoAction does, "error" if it refuses
+		action string
+	}{
+		{name: "vanilla", asars: map[string]map[string][]byte{"app.asar": vanilla}, want: StateVanilla, action: "install"},
+		{name: "patched", asars: map[string]map[string][]byte{"_app.asar": vanilla}, files: map[string][]byte{"app.asar": stub(Patcher)}, want: StatePatched},
+		{name: "openasar", asars: map[string]map[string][]byte{"app.asar": openAsar}, want: StateOpenAsar, action: "install"},
+		{name: "openasar patched", asars: map[string]map[string][]byte{"_app.asar": openAsar}, files: map[string][]byte{"app.asar": stub(Patcher)}, want: StateOpenAsarPatched},
+		{name: "stale patch", asars: map[string]map[string][]byte{"app.asar": vanilla, "_app.asar": vanilla}, want: StateStalePatch, action: "install"},
+		{name: "other mod", asars: map[string]map[string][]byte{"_app.asar": vanilla}, files: map[string][]byte{"app.asar": stub("/opt/BetterDiscord/betterdiscord.asar")}, want: StateForeignMod, action: "migrate"},
+		{name: "store", asars: map[string]map[string][]byte{"app.asar": vanilla}, store: "nix", want: StateReadOnlyStore, action: "error"},
+		{name: "missing", want: StateBroken, action: "error"},
+		{name: "not an asar", files: map[string][]byte{"app.asar": []byte("<html>")}, want: StateBroken, action: "error"},
+		{name: "original gone", files: map[string][]byte{"app.asar": stub(Patcher)}, want: StateBroken, action: "error"},
+		{name: "original is a stub", files: map[string][]byte{"app.asar": stub(Patcher), "_app.asar": stub(Patcher)}, want: StateBroken, action: "error"},
+		{
+			name:   "leftover stub",
+			asars:  map[string]map[string][]byte{"_app.asar": vanilla},
+			files:  map[string][]byte{"app.asar": stub(Patcher), "app.asar.tmp": stub(Patcher)},
+			want:   StateBroken,
+			action: "install",
+		},
+		{
+			name:   "leftover discord files",
+			asars:  map[string]map[string][]byte{"_app.asar": vanilla, "app.asar.tmp": vanilla},
+			files:  map[string][]byte{"app.asar": stub(Patcher)},
+			want:   StateBroken,
+			action: "error",
+		},
+		{name: "interrupted", asars: map[string]map[string][]byte{"app.asar": vanilla}, pending: true, want: StateBroken, action: "error"},
+		{
+			name:    "interrupted and leftover stub",
+			asars:   map[string]map[string][]byte{"_app.asar": vanilla},
+			files:   map[string][]byte{"app.asar": stub(Patcher), "app.asar.tmp": stub(Patcher)},
+			pending: true,
+			want:    StateBroken,
+			action:  "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			dir := t.TempDir()
+			resources := path.Join(dir, "resources")
+			if err := os.MkdirAll(resources, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, files := range tt.asars {
+				if err := asar.PackMap(path.Join(resources, name), files, asar.Options{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, data := range tt.files {
+				if err := os.WriteFile(path.Join(resources, name), data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			di := &DiscordInstall{path: dir, appPath: path.Join(resources, "app"), store: tt.store}
+			if tt.pending {
+				plan := NewPlan("patch", di)
+				plan.Write(path.Join(resources, "app.asar"), nil)
+				if err := plan.save(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// so no Discord settings of the machine running the test show up as a mod
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_CONFIG_HOME", home)
+			t.Setenv("SUDO_USER", "")
+			di.refreshState()
+			if di.status.State != tt.want {
+				t.Fatalf("state is %s %v, want %s", di.status.State, di.status.Reasons, tt.want)
+			}
+			if tt.want == StateBroken && len(di.status.Reasons) == 0 {
+				t.Error("broken without a reason")
+			}
+			if tt.action == "" {
+				return
+			}
+			action, err := di.AutoAction()
+			if got := Ternary(err != nil, "error", action); got != tt.action {
+				t.Errorf("AutoAction is %q (%v), want %q", action, err, tt.action)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+)
+
+func journalDir() string {
+	return path.Join(BaseDir, "journal")
+}
+
+func (p *Plan) save() error {
+	if p.journalFile == "" {
+		dir := journalDir()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		_ = FixOwnership(dir)
+		p.journalFile = path.Join(dir, p.Id+".json")
+	}
+
+	b, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename it over the old journal so the journal itself can't end up half written
+	tmp := p.journalFile + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, p.journalFile); err != nil {
+		return err
+	}
+	_ = FixOwnership(p.journalFile)
+	return nil
+}
+
+func (p *Plan) discard() {
+	if p.journalFile == "" {
+		return
+	}
+	if err := os.Remove(p.journalFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		Log.Warn("Failed to delete journal", p.journalFile+":", err)
+	}
+}
+
+// PendingPlans returns all journaled plans that never finished, oldest first
+func PendingPlans() []*Plan {
+	dir := journalDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			Log.Warn("Failed to read journal dir", dir+":", err)
+		}
+		return nil
+	}
+
+	var plans []*Plan
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		file := path.Join(dir, entry.Name())
+		b, err := os.ReadFile(file)
+		if err != nil {
+			Log.Warn("Failed to read journal", file+":", err)
+			continue
+		}
+
+		var p Plan
+		if err = json.Unmarshal(b, &p); err != nil {
+			Log.Warn("Ignoring corrupt journal", file+":", err)
+			continue
+		}
+		p.journalFile = file
+		plans = append(plans, &p)
+	}
+
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].Created.Before(plans[j].Created)
+	})
+	return plans
+}
+
+// FindPendingPlan returns the unfinished plan for the install at any of installPaths, if there is one
+func FindPendingPlan(installPaths ...string) *Plan {
+	for _, p := range PendingPlans() {
+		if SliceContains(installPaths, p.Install) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Recover finishes (forward) or undoes (!forward) a plan that was interrupted
+func (p *Plan) Recover(forward bool) error {
+	Log.Info(Ternary(forward, "Finishing", "Undoing"), p.String())
+	p.fixInterruptedStep()
+
+	var err error
+	if forward {
+		err = p.RollForward()
+	} else {
+		err = p.RollBack()
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to recover %s: %w", p.Action, err)
+	}
+
+	Log.Info("Successfully recovered", p.Install)
+	return nil
+}
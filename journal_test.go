@@ -0,0 +1,141 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"testing"
+	"time"
+)
+
+// checkFiles fails t unless every file in want has the given content, or doesn't exist if that is empty
+func checkFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	for name, content := range want {
+		b, err := os.ReadFile(path.Join(dir, name))
+		if content == "" {
+			if err == nil {
+				t.Errorf("%s exists with %q, want it gone", name, b)
+			}
+		} else if err != nil || string(b) != content {
+			t.Errorf("%s is %q (%v), want %q", name, b, err, content)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	patched := map[string]string{"app.asar": "stub", "_app.asar": "vanilla"}
+	vanilla := map[string]string{"app.asar": "vanilla", "_app.asar": ""}
+
+	tests := []struct {
+		name string
+		// steps done according to the journal
+		done int
+		// files the interrupted run left behind
+		files   map[string]string
+		forward bool
+		want    map[string]string
+	}{
+		{"rename happened, finish", 0, map[string]string{"_app.asar": "vanilla"}, true, patched},
+		{"rename happened, undo", 0, map[string]string{"_app.asar": "vanilla"}, false, vanilla},
+		{"rename didn't happen, finish", 0, map[string]string{"app.asar": "vanilla"}, true, patched},
+		{"rename didn't happen, undo", 0, map[string]string{"app.asar": "vanilla"}, false, vanilla},
+		{"partial write, finish", 1, map[string]string{"_app.asar": "vanilla", "app.asar": "st"}, true, patched},
+		{"partial write, undo", 1, map[string]string{"_app.asar": "vanilla", "app.asar": "st"}, false, vanilla},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plan := NewPlan("patch", &DiscordInstall{path: dir})
+			plan.Rename(path.Join(dir, "app.asar"), path.Join(dir, "_app.asar"))
+			plan.Write(path.Join(dir, "app.asar"), []byte("stub"))
+			for _, step := range plan.Steps[:tt.done] {
+				step.Done = true
+			}
+			if err := plan.save(); err != nil {
+				t.Fatal(err)
+			}
+
+			pending := FindPendingPlan(dir)
+			if pending == nil {
+				t.Fatal("the journaled plan isn't pending")
+			}
+			if err := pending.Recover(tt.forward); err != nil {
+				t.Fatal(err)
+			}
+			checkFiles(t, dir, tt.want)
+			if pending = FindPendingPlan(dir); pending != nil {
+				t.Errorf("%s is still pending after recovering it", pending)
+			}
+		})
+	}
+}
+
+func TestPendingPlans(t *testing.T) {
+	useTempBaseDir(t)
+	dir := t.TempDir()
+
+	newer := NewPlan("repair", &DiscordInstall{path: dir})
+	older := NewPlan("patch", &DiscordInstall{path: dir})
+	older.Id, older.Created = "older", newer.Created.Add(-time.Hour)
+	for _, p := range []*Plan{newer, older} {
+		p.Write(path.Join(dir, "app.asar"), []byte("stub"))
+		if err := p.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path.Join(journalDir(), "corrupt.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plans := PendingPlans()
+	if len(plans) != 2 || plans[0].Action != "patch" || plans[1].Action != "repair" {
+		t.Fatalf("pending plans are %v, want the patch and then the repair", plans)
+	}
+	if p := FindPendingPlan(path.Join(dir, "other")); p != nil {
+		t.Errorf("found %s for another install", p)
+	}
+
+	// Nothing may run before the interrupted plans are recovered
+	plan := NewPlan("unpatch", &DiscordInstall{path: dir})
+	plan.Write(path.Join(dir, "app.asar"), []byte("stub"))
+	if err := plan.Execute(); err == nil {
+		t.Error("executed a plan with an interrupted one pending")
+	}
+	checkFiles(t, dir, map[string]string{"app.asar": ""})
+}
+
+func TestExecuteRollsBack(t *testing.T) {
+	useTempBaseDir(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(path.Join(dir, "app.asar"), []byte("vanilla"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan("patch", &DiscordInstall{path: dir})
+	plan.Rename(path.Join(dir, "app.asar"), path.Join(dir, "_app.asar"))
+	plan.Write(path.Join(dir, "app.asar"), []byte("stub"))
+	// fails, there is nothing to rename
+	plan.Rename(path.Join(dir, "missing"), path.Join(dir, "other"))
+
+	if err := plan.Execute(); err == nil {
+		t.Fatal("expected an error")
+	}
+	checkFiles(t, dir, map[string]string{"app.asar": "vanilla", "_app.asar": ""})
+	if plans := PendingPlans(); len(plans) != 0 {
+		t.Errorf("kept %v after undoing everything", plans)
+	}
+}
@@ -7,20 +7,19 @@
 package main
 
 import (
-	"bytes"
 	"errors"
-	"io"
-	"net/http"
 	"os"
 	path "path/filepath"
-	"strconv"
+	"vencordinstaller/asar"
 )
 
 const OpenAsarDownloadLink = "https://github.com/GooseMod/OpenAsar/releases/download/nightly/app.asar"
 
-func FindAsarFile(dir string) (*os.File, error) {
-	for _, file := range []string{"_app.asar", "app.asar"} {
-		f, err := os.Open(path.Join(dir, file))
+// FindAsarFile opens the asar with Discord's code: the original if it is patched, otherwise appAsar itself
+func FindAsarFile(appAsar string) (*os.File, error) {
+	dir := path.Dir(appAsar)
+	for _, file := range []string{originalAsarOf(appAsar), appAsar} {
+		f, err := os.Open(file)
 		if err != nil {
 			continue
 		}
@@ -33,74 +32,79 @@ func FindAsarFile(dir string) (*os.File, error) {
 	return nil, errors.New("Install at " + dir + " has no asar file")
 }
 
-func (di *DiscordInstall) IsOpenAsar() (retBool bool) {
-	if di.isOpenAsar != nil {
-		return *di.isOpenAsar
-	}
+// IsOpenAsar reports whether di runs OpenAsar, with or without Vencord on top of it
+func (di *DiscordInstall) IsOpenAsar() bool {
+	return di.status.State == StateOpenAsar || di.status.State == StateOpenAsarPatched
+}
 
+func isOpenAsarFile(asarPath string) (retBool bool) {
 	defer func() {
-		Log.Debug("Checking if", di.path, "is using OpenAsar:", retBool)
-		di.isOpenAsar = &retBool
+		Log.Debug("Checking if", asarPath, "is OpenAsar:", retBool)
 	}()
 
-	asarFile, err := FindAsarFile(path.Join(di.appPath, ".."))
+	archive, err := asar.Open(asarPath)
 	if err != nil {
-		Log.Error(err.Error())
+		Log.Debug("Failed to read", asarPath+":", err)
 		return false
 	}
+	defer archive.Close()
 
-	b, err := io.ReadAll(asarFile)
-	_ = asarFile.Close()
-	if err != nil {
-		Log.Error(err.Error())
-		return false
-	}
+	return IsOpenAsarArchive(archive)
+}
 
-	if bytes.Contains(b, []byte("OpenAsar")) {
-		return true
+// IsOpenAsarArchive checks the header of an app asar for OpenAsar's layout: it updates itself with asarUpdate.js
+// at its root, while Discord's own app.asar starts from app_bootstrap
+func IsOpenAsarArchive(archive *asar.Archive) bool {
+	if _, err := archive.Lookup("app_bootstrap"); err == nil {
+		return false
 	}
-
-	return false
+	e, err := archive.Lookup("asarUpdate.js")
+	return err == nil && !e.IsDir() && !e.IsLink()
 }
 
-func (di *DiscordInstall) InstallOpenAsar() error {
-	PreparePatch(di)
+// PlanInstallOpenAsar computes everything installing OpenAsar on di does, without touching anything
+func (di *DiscordInstall) PlanInstallOpenAsar() (*Plan, error) {
+	if di.store != "" {
+		return nil, di.ErrReadOnlyStore()
+	}
+	if di.foreignMod != nil {
+		return nil, di.ErrForeignMod()
+	}
 
-	dir := path.Join(di.appPath, "..")
-	asarFile, err := FindAsarFile(dir)
+	dir := di.asarDir()
+	asarFile, err := FindAsarFile(di.appAsar())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	_ = asarFile.Close()
 
-	if err = os.Rename(asarFile.Name(), path.Join(dir, "app.asar.backup")); err != nil {
-		return err
-	}
+	plan := NewPlan("OpenAsar install", di)
+	plan.Rename(asarFile.Name(), path.Join(dir, "app.asar.backup"))
+	plan.Download(OpenAsarDownloadLink, asarFile.Name())
+	return plan, nil
+}
 
-	res, err := http.Get(OpenAsarDownloadLink)
+func (di *DiscordInstall) InstallOpenAsar() error {
+	plan, err := di.PlanInstallOpenAsar()
 	if err != nil {
 		return err
-	} else if res.StatusCode >= 300 {
-		return errors.New("Failed to fetch OpenAsar - " + strconv.Itoa(res.StatusCode) + ": " + res.Status)
 	}
 
-	outFile, err := os.Create(asarFile.Name())
-	if err != nil {
+	if err = PreparePatch(di); err != nil {
 		return err
 	}
 
-	if _, err = io.Copy(outFile, res.Body); err != nil {
+	if err = plan.Execute(); err != nil {
 		return err
 	}
 
-	di.isOpenAsar = Ptr(true)
+	di.refreshState()
 	return nil
 }
 
-func (di *DiscordInstall) UninstallOpenAsar() error {
-	PreparePatch(di)
-
-	dir := path.Join(di.appPath, "..")
+// PlanUninstallOpenAsar computes everything uninstalling OpenAsar from di does, without touching anything
+func (di *DiscordInstall) PlanUninstallOpenAsar() (*Plan, error) {
+	dir := di.asarDir()
 	// .original is our old name
 	// OpenAsar's updater uses .backup, so we now also use that - .original is deprecated
 	for _, file := range []string{path.Join(dir, "app.asar.backup"), path.Join(dir, "app.asar.original")} {
@@ -108,19 +112,36 @@ func (di *DiscordInstall) UninstallOpenAsar() error {
 			continue
 		}
 
-		asarFile, err := FindAsarFile(dir)
+		asarFile, err := FindAsarFile(di.appAsar())
 		if err != nil {
-			return err
+			return nil, err
 		}
 		_ = asarFile.Close()
 
-		if err = os.Rename(file, asarFile.Name()); err != nil {
-			return err
-		}
+		plan := NewPlan("OpenAsar uninstall", di)
+		plan.Rename(asarFile.Name(), asarFile.Name()+".tmp")
+		plan.Rename(file, asarFile.Name())
+		plan.Remove(asarFile.Name() + ".tmp")
+		return plan, nil
+	}
 
-		di.isOpenAsar = Ptr(false)
-		return nil
+	return nil, errors.New("No app.asar.backup. Reinstall Discord")
+}
+
+func (di *DiscordInstall) UninstallOpenAsar() error {
+	plan, err := di.PlanUninstallOpenAsar()
+	if err != nil {
+		return err
+	}
+
+	if err = PreparePatch(di); err != nil {
+		return err
 	}
 
-	return errors.New("No app.asar.backup. Reinstall Discord")
+	if err = plan.Execute(); err != nil {
+		return err
+	}
+
+	di.refreshState()
+	return nil
 }
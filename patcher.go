@@ -10,9 +10,7 @@ import (
 	"errors"
 	"github.com/ProtonMail/go-appdir"
 	"os"
-	"os/exec"
 	path "path/filepath"
-	"strings"
 )
 
 var BaseDir string
@@ -32,6 +30,7 @@ func init() {
 		BaseDir = appdir.New("Vencord").UserConfig()
 	}
 	FilesDir = path.Join(BaseDir, "dist")
+	recoverDist()
 	if !ExistsFile(FilesDir) {
 		FilesDirErr = os.MkdirAll(FilesDir, 0755)
 		if FilesDirErr != nil {
@@ -44,135 +43,215 @@ func init() {
 }
 
 type DiscordInstall struct {
-	path             string // the base path
-	branch           string // canary / stable / ...
-	appPath          string // List of app folder to patch
-	isPatched        bool
-	isFlatpak        bool
-	isSystemElectron bool // Needs special care https://aur.archlinux.org/packages/discord_arch_electron
-	isOpenAsar       *bool
+	path      string // the base path
+	branch    string // canary / stable / ...
+	appPath   string // List of app folder to patch
+	isFlatpak bool
+	// app id and installation (user, system or a custom one) of a Flatpak
+	flatpakId           string
+	flatpakInstallation string
+	isSystemElectron    bool   // Needs special care https://aur.archlinux.org/packages/discord_arch_electron
+	asarName            string // the asar a system Electron runs, if its launcher doesn't use app.asar
+	status              InstallStatus
+	foreignMod          *ForeignMod // another client mod that has to be removed before we can patch
+	store               string      // nix or guix if the install is in their read-only store
+	aliases             []string    // other paths leading to the same install, like symlinks to it
+}
+
+// Paths returns the path of di and all its aliases
+func (di *DiscordInstall) Paths() []string {
+	return append([]string{di.path}, di.aliases...)
+}
+
+func (di *DiscordInstall) addAlias(p string) {
+	if p != di.path && !SliceContains(di.aliases, p) {
+		di.aliases = append(di.aliases, p)
+	}
+}
+
+// PackageType is how this install was packaged: native, flatpak or system-electron
+func (di *DiscordInstall) PackageType() string {
+	switch {
+	case di.isFlatpak:
+		return "flatpak"
+	case di.isSystemElectron:
+		return "system-electron"
+	default:
+		return "native"
+	}
+}
+
+// asarDir is the directory containing app.asar
+func (di *DiscordInstall) asarDir() string {
+	if di.isSystemElectron {
+		return di.path
+	}
+	return path.Join(di.appPath, "..")
+}
+
+// appAsar is the asar Discord starts, which patching replaces with the stub
+func (di *DiscordInstall) appAsar() string {
+	return path.Join(di.asarDir(), Ternary(di.asarName != "", di.asarName, "app.asar"))
+}
+
+// originalAsarOf returns where patching moves appAsar to, as Vencord's patcher loads Discord from there: _app.asar
+// if the stub is named app.asar, otherwise app.asar next to the stub
+func originalAsarOf(appAsar string) string {
+	return path.Join(path.Dir(appAsar), Ternary(path.Base(appAsar) == "app.asar", "_app.asar", "app.asar"))
+}
+
+// originalAsar is where patching moved Discord's original app.asar of di to
+func (di *DiscordInstall) originalAsar() string {
+	return originalAsarOf(di.appAsar())
 }
 
 //region Patch
 
-func patchAppAsar(dir string, isSystemElectron bool) (err error) {
-	appAsar := path.Join(dir, "app.asar")
-	_appAsar := path.Join(dir, "_app.asar")
-
-	var renamesDone [][]string
-	defer func() {
-		if err != nil && len(renamesDone) > 0 {
-			Log.Error("Failed to patch. Undoing partial patch")
-			for _, rename := range renamesDone {
-				if innerErr := os.Rename(rename[1], rename[0]); innerErr != nil {
-					Log.Error("Failed to undo partial patch. This install is probably bricked.", innerErr)
-				} else {
-					Log.Info("Successfully undid all changes")
-				}
-			}
-		}
-	}()
+// planPatchAppAsar moves appAsar to where the patcher loads Discord from and puts the stub in its place. That is
+// _app.asar, or app.asar if a system Electron launcher names appAsar differently, see originalAsarOf
+func planPatchAppAsar(plan *Plan, appAsar, patcher string, isSystemElectron, isPatched bool) error {
+	_appAsar := originalAsarOf(appAsar)
 
-	Log.Debug("Renaming", appAsar, "to", _appAsar)
-	if err := os.Rename(appAsar, _appAsar); err != nil {
-		err = CheckIfErrIsCauseItsBusyRn(err)
-		Log.Error(err.Error())
+	stub, err := BuildAppAsar(patcher)
+	if err != nil {
 		return err
 	}
-	renamesDone = append(renamesDone, []string{appAsar, _appAsar})
 
-	if isSystemElectron {
-		from, to := appAsar+".unpacked", _appAsar+".unpacked"
-		Log.Debug("Renaming", from, "to", to)
-		err := os.Rename(from, to)
-		if err != nil {
-			return err
+	if isPatched {
+		// The original is already out of the way, only swap out the stub
+		planRemoveLeftoverStub(plan, appAsar)
+		if isSystemElectron && !ExistsFile(_appAsar+".unpacked") && ExistsFile(appAsar+".unpacked") {
+			plan.Rename(appAsar+".unpacked", _appAsar+".unpacked")
 		}
-		renamesDone = append(renamesDone, []string{from, to})
+		plan.Rename(appAsar, appAsar+".tmp")
+		plan.Write(appAsar, stub).Description = "stub " + path.Base(appAsar) + " requiring " + patcher
+		plan.Remove(appAsar + ".tmp")
+		return nil
 	}
 
-	Log.Debug("Writing custom app.asar to", appAsar)
-	if err := WriteAppAsar(appAsar, Patcher); err != nil {
-		return err
+	// Discord updated and replaced our stub, so the original is the previous Discord version
+	stale := ExistsFile(_appAsar)
+	if stale {
+		plan.Rename(_appAsar, _appAsar+".old")
+		if isSystemElectron && ExistsFile(_appAsar+".unpacked") {
+			plan.Rename(_appAsar+".unpacked", _appAsar+".unpacked.old")
+		}
 	}
 
+	plan.Rename(appAsar, _appAsar)
+	if isSystemElectron {
+		plan.Rename(appAsar+".unpacked", _appAsar+".unpacked")
+	}
+	plan.Write(appAsar, stub).Description = "stub " + path.Base(appAsar) + " requiring " + patcher
+
+	if stale {
+		plan.Remove(_appAsar + ".old")
+		if isSystemElectron {
+			plan.Remove(_appAsar + ".unpacked.old")
+		}
+	}
 	return nil
 }
 
-func (di *DiscordInstall) patch() error {
-	Log.Info("Patching " + di.path + "...")
-	if LatestHash != InstalledHash {
-		if err := InstallLatestBuilds(); err != nil {
-			return nil // already shown dialog so don't return same error again
-		}
+// planRemoveLeftoverStub deletes the stub an unfinished uninstall or repair left behind as app.asar.tmp,
+// so it doesn't get in the way of renaming app.asar there. It's only a stub, so there is nothing to undo
+func planRemoveLeftoverStub(plan *Plan, appAsar string) {
+	if tmp := appAsar + ".tmp"; ExistsFile(tmp) && IsStubAsar(tmp) {
+		plan.Remove(tmp).Description = "leftover stub"
 	}
+}
 
-	PreparePatch(di)
+// PlanPatch computes everything patching di does, without touching anything
+func (di *DiscordInstall) PlanPatch() (*Plan, error) {
+	return di.planPatch("patch", !IsDevInstall && IsDistOutdated())
+}
 
-	if di.isPatched {
-		Log.Info(di.path, "is already patched. Unpatching first...")
-		if err := di.unpatch(); err != nil {
-			if errors.Is(err, os.ErrPermission) {
-				return err
-			}
-			return errors.New("patch: Failed to unpatch already patched install '" + di.path + "':\n" + err.Error())
-		}
+// PlanRepair is PlanPatch, but always downloads Vencord again even if it is up to date
+func (di *DiscordInstall) PlanRepair() (*Plan, error) {
+	return di.planPatch("repair", !IsDevInstall)
+}
+
+// planPatch plans patching di. The dist is only downloaded if withDist is set, so patching several installs
+// can download it once up front
+func (di *DiscordInstall) planPatch(action string, withDist bool) (*Plan, error) {
+	if di.store != "" {
+		return nil, di.ErrReadOnlyStore()
+	}
+	if di.foreignMod != nil {
+		return nil, di.ErrForeignMod()
 	}
 
-	if di.isSystemElectron {
-		if err := patchAppAsar(di.path, true); err != nil {
-			return err
-		}
-	} else {
-		if err := patchAppAsar(path.Join(di.appPath, ".."), false); err != nil {
-			return err
+	// Broken installs that patching can fix still have the stub and the original in place
+	stubbed := di.IsPatched() || di.status.State == StateBroken
+	if di.status.State == StateBroken && di.status.fix != "install" {
+		_, err := di.AutoAction()
+		return nil, err
+	}
+
+	plan := NewPlan(action, di)
+
+	if withDist {
+		plan.InstallDist()
+	}
+
+	if !stubbed {
+		if err := di.planBackup(plan); err != nil {
+			return nil, err
 		}
 	}
 
-	Log.Info("Successfully patched", di.path)
-	di.isPatched = true
+	if err := planPatchAppAsar(plan, di.appAsar(), di.patcherPath(), di.isSystemElectron, stubbed); err != nil {
+		return nil, err
+	}
 
 	if di.isFlatpak {
-		pathElements := strings.Split(di.path, "/")
-		var name string
-		for _, e := range pathElements {
-			if strings.HasPrefix(e, "com.discordapp") {
-				name = e
-				break
-			}
-		}
+		di.planFlatpak(plan)
+	}
+	return plan, nil
+}
 
-		Log.Debug("This is a flatpak. Trying to grant the Flatpak access to", FilesDir+"...")
+func (di *DiscordInstall) patch() error {
+	Log.Info("Patching " + di.path + "...")
+	return di.executePatch(di.PlanPatch())
+}
 
-		isSystemFlatpak := strings.HasPrefix(di.path, "/var")
-		var args []string
-		if !isSystemFlatpak {
-			args = append(args, "--user")
-		}
-		args = append(args, "override", name, "--filesystem="+FilesDir)
-		fullCmd := "flatpak " + strings.Join(args, " ")
-
-		Log.Debug("Running", fullCmd)
-
-		var err error
-		if !isSystemFlatpak && os.Getuid() == 0 {
-			// We are operating on a user flatpak but are root
-			actualUser := os.Getenv("SUDO_USER")
-			Log.Debug("This is a user install but we are root. Using su to run as", actualUser)
-			cmd := exec.Command("su", "-", actualUser, "-c", "sh", "-c", fullCmd)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-		} else {
-			cmd := exec.Command("flatpak", args...)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-		}
-		if err != nil {
-			return errors.New("Failed to grant Discord Flatpak access to " + FilesDir + ": " + err.Error())
+func (di *DiscordInstall) repair() error {
+	Log.Info("Repairing " + di.path + "...")
+	return di.executePatch(di.PlanRepair())
+}
+
+func (di *DiscordInstall) executePatch(plan *Plan, err error) error {
+	if err != nil {
+		Log.Error(err.Error())
+		return err
+	}
+
+	if err = PreparePatch(di); err != nil {
+		Log.Error(err.Error())
+		return err
+	}
+
+	if err = plan.Execute(); err != nil {
+		return err
+	}
+
+	if ExistsFile(backupsDir()) {
+		_ = FixOwnership(backupsDir())
+	}
+	if di.isFlatpak {
+		sandboxed := di.flatpakMode() == FlatpakModeSandbox
+		di.trackFlatpakOverride(!sandboxed && di.ownsFlatpakOverride(plan))
+		if sandboxed {
+			_ = FixOwnership(path.Dir(di.sandboxFilesDir()))
 		}
 	}
+
+	if err = SavePinnedVersions(); err != nil {
+		Log.Warn("Failed to remember the version that was installed:", err)
+	}
+
+	Log.Info("Successfully patched", di.path)
+	di.refreshState()
 	return nil
 }
 
@@ -180,74 +259,60 @@ func (di *DiscordInstall) patch() error {
 
 // region Unpatch
 
-func unpatchAppAsar(dir string, isSystemElectron bool) (errOut error) {
-	appAsar := path.Join(dir, "app.asar")
-	appAsarTmp := path.Join(dir, "app.asar.tmp")
-	_appAsar := path.Join(dir, "_app.asar")
-
-	var renamesDone [][]string
-	defer func() {
-		if errOut != nil && len(renamesDone) > 0 {
-			Log.Error("Failed to unpatch. Undoing partial unpatch")
-			for _, rename := range renamesDone {
-				if innerErr := os.Rename(rename[1], rename[0]); innerErr != nil {
-					Log.Error("Failed to undo partial unpatch. This install is probably bricked.", innerErr)
-				} else {
-					Log.Info("Successfully undid all changes")
-				}
-			}
-		} else if errOut == nil {
-			if innerErr := os.RemoveAll(appAsarTmp); innerErr != nil {
-				Log.Warn("Failed to delete temporary app.asar (patch folder) backup. This is whatever but you might want to delete it manually.", innerErr)
-			}
-		}
-	}()
+func planUnpatchAppAsar(plan *Plan, appAsar string, isSystemElectron bool) {
+	appAsarTmp := appAsar + ".tmp"
+	_appAsar := originalAsarOf(appAsar)
 
-	Log.Debug("Deleting", appAsar)
-	if err := os.Rename(appAsar, appAsarTmp); err != nil {
-		err = CheckIfErrIsCauseItsBusyRn(err)
-		Log.Error(err.Error())
-		errOut = err
-	} else {
-		renamesDone = append(renamesDone, []string{appAsar, appAsarTmp})
+	planRemoveLeftoverStub(plan, appAsar)
+	plan.Rename(appAsar, appAsarTmp)
+	plan.Rename(_appAsar, appAsar)
+	if isSystemElectron && ExistsFile(_appAsar+".unpacked") {
+		plan.Rename(_appAsar+".unpacked", appAsar+".unpacked")
 	}
+	// the old app.asar (patch stub) is only deleted once everything else worked
+	plan.Remove(appAsarTmp)
+}
 
-	Log.Debug("Renaming", _appAsar, "to", appAsar)
-	if err := os.Rename(_appAsar, appAsar); err != nil {
-		err = CheckIfErrIsCauseItsBusyRn(err)
-		Log.Error(err.Error())
-		errOut = err
-	} else {
-		renamesDone = append(renamesDone, []string{_appAsar, appAsar})
+// PlanUnpatch computes everything unpatching di does, without touching anything
+func (di *DiscordInstall) PlanUnpatch() (*Plan, error) {
+	if !di.IsPatched() && (di.status.State != StateBroken || di.status.fix != "install") {
+		return nil, errors.New(di.path + " is not patched (" + di.status.State.Label() + ")")
 	}
 
-	if isSystemElectron {
-		Log.Debug("Renaming", _appAsar+".unpacked", "to", appAsar+".unpacked")
-		if err := os.Rename(_appAsar+".unpacked", appAsar+".unpacked"); err != nil {
-			Log.Error(err.Error())
-			errOut = err
+	plan := NewPlan("unpatch", di)
+	planUnpatchAppAsar(plan, di.appAsar(), di.isSystemElectron)
+	if di.isFlatpak {
+		di.planRevokeFlatpakOverride(plan)
+		if dir := di.sandboxFilesDir(); ExistsFile(dir) {
+			plan.Remove(dir).Description = "copy of Vencord in the Flatpak sandbox"
 		}
 	}
-	return
+	return plan, nil
 }
 
 func (di *DiscordInstall) unpatch() error {
 	Log.Info("Unpatching " + di.path + "...")
 
-	PreparePatch(di)
+	plan, err := di.PlanUnpatch()
+	if err != nil {
+		Log.Error(err.Error())
+		return err
+	}
+
+	if err = PreparePatch(di); err != nil {
+		Log.Error(err.Error())
+		return err
+	}
 
-	if di.isSystemElectron {
-		if err := unpatchAppAsar(di.path, true); err != nil {
-			return err
-		}
-	} else {
-		if err := unpatchAppAsar(path.Join(di.appPath, ".."), false); err != nil {
-			return err
-		}
+	if err = plan.Execute(); err != nil {
+		return err
+	}
+	if di.isFlatpak {
+		di.trackFlatpakOverride(false)
 	}
 
 	Log.Info("Successfully unpatched", di.path)
-	di.isPatched = false
+	di.refreshState()
 	return nil
 }
 
@@ -0,0 +1,374 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type StepKind string
+
+const (
+	// StepRename renames Source to Target. Undone by renaming it back
+	StepRename StepKind = "rename"
+	// StepWrite writes Data to Target, which must not exist. Undone by deleting Target
+	StepWrite StepKind = "write"
+	// StepDownload downloads Url to Target, which must not exist. Undone by deleting Target
+	StepDownload StepKind = "download"
+	// StepCopy copies the file or directory Source to Target, which must not exist. Undone by deleting Target
+	StepCopy StepKind = "copy"
+	// StepCommand runs Args, as the user RunAs if set. Can't be undone
+	StepCommand StepKind = "command"
+	// StepInstallDist downloads the latest Vencord build and swaps it in for the one in FilesDir once it is
+	// complete. Not undone, the previous build is simply replaced and is compatible with the patch anyway
+	StepInstallDist StepKind = "install-dist"
+	// StepRemove deletes Target. This can't be undone, so it must only be used for cleanup at the very end.
+	// Failing to delete is only logged
+	StepRemove StepKind = "remove"
+)
+
+type Step struct {
+	Kind   StepKind `json:"kind"`
+	Source string   `json:"source,omitempty"`
+	Target string   `json:"target,omitempty"`
+	Url    string   `json:"url,omitempty"`
+	Data   []byte   `json:"data,omitempty"`
+	Size   int      `json:"size,omitempty"`
+	Args   []string `json:"args,omitempty"`
+	RunAs  string   `json:"runAs,omitempty"`
+	// Human readable explanation of what this step is for
+	Description string `json:"description,omitempty"`
+	Done        bool   `json:"done"`
+}
+
+// Plan is an ordered list of filesystem steps that together make up one operation like patching an install.
+// Before the first step runs, the plan is written to the journal so a run that gets interrupted half way
+// (crash, power loss, killed process) can be finished or undone on the next launch, see journal.go
+type Plan struct {
+	Id      string    `json:"id"`
+	Action  string    `json:"action"`
+	Install string    `json:"install"`
+	Created time.Time `json:"created"`
+	Steps   []*Step   `json:"steps"`
+
+	journalFile string
+}
+
+func NewPlan(action string, di *DiscordInstall) *Plan {
+	now := time.Now()
+	return &Plan{
+		Id:      strconv.FormatInt(now.UnixNano(), 36),
+		Action:  action,
+		Install: di.path,
+		Created: now,
+	}
+}
+
+func (p *Plan) add(step *Step) *Step {
+	p.Steps = append(p.Steps, step)
+	return step
+}
+
+func (p *Plan) Rename(from, to string) *Step {
+	return p.add(&Step{Kind: StepRename, Source: from, Target: to})
+}
+
+func (p *Plan) Write(target string, data []byte) *Step {
+	return p.add(&Step{Kind: StepWrite, Target: target, Data: data, Size: len(data)})
+}
+
+func (p *Plan) Download(url, target string) *Step {
+	return p.add(&Step{Kind: StepDownload, Target: target, Url: url})
+}
+
+func (p *Plan) Copy(from, to string) *Step {
+	return p.add(&Step{Kind: StepCopy, Source: from, Target: to})
+}
+
+func (p *Plan) Command(args ...string) *Step {
+	return p.add(&Step{Kind: StepCommand, Args: args})
+}
+
+func (p *Plan) InstallDist() *Step {
+	step := p.add(&Step{Kind: StepInstallDist, Target: FilesDir})
+	rel := latestRelease()
+	step.Description = rel.source.Name + " " + rel.hash + ": " + strings.Join(SliceMap(rel.distAssets(), func(a GithubAsset) string { return a.Name }), ", ")
+	return step
+}
+
+func (p *Plan) Remove(target string) *Step {
+	return p.add(&Step{Kind: StepRemove, Target: target})
+}
+
+func (p *Plan) String() string {
+	done := 0
+	for _, step := range p.Steps {
+		if step.Done {
+			done++
+		}
+	}
+	return fmt.Sprintf("%s of %s started %s (%d/%d steps done)", p.Action, p.Install, p.Created.Format("2006-01-02 15:04:05"), done, len(p.Steps))
+}
+
+// Describe writes a human readable list of all steps of the plan to w
+func (p *Plan) Describe(w io.Writer) {
+	fmt.Fprintf(w, "%s of %s (%d steps)\n", p.Action, p.Install, len(p.Steps))
+	for i, s := range p.Steps {
+		fmt.Fprintf(w, "%3d. %s", i+1, s.Kind)
+		switch s.Kind {
+		case StepRename, StepCopy:
+			fmt.Fprintf(w, " %s\n       -> %s\n", s.Source, s.Target)
+		case StepWrite:
+			hash := sha256.Sum256(s.Data)
+			fmt.Fprintf(w, " %s (%d bytes, sha256 %s)\n", s.Target, len(s.Data), hex.EncodeToString(hash[:]))
+		case StepDownload:
+			fmt.Fprintf(w, " %s\n       -> %s\n", s.Url, s.Target)
+		case StepCommand:
+			fmt.Fprintf(w, " %s\n", strings.Join(s.Args, " "))
+			if s.RunAs != "" {
+				fmt.Fprintf(w, "       as user %s\n", s.RunAs)
+			}
+		default:
+			fmt.Fprintf(w, " %s\n", s.Target)
+		}
+
+		if s.Description != "" {
+			fmt.Fprintf(w, "       %s\n", s.Description)
+		}
+		if s.Kind == StepWrite && isText(s.Data) {
+			for _, line := range strings.Split(strings.TrimRight(string(s.Data), "\n"), "\n") {
+				fmt.Fprintf(w, "       | %s\n", line)
+			}
+		}
+	}
+}
+
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
+// Execute journals the plan, then runs all steps in order. If a step fails, all previous steps are undone.
+// The journal is only kept if undoing fails as well
+func (p *Plan) Execute() (err error) {
+	if pending := FindPendingPlan(p.Install); pending != nil {
+		err = errors.New("An earlier " + pending.Action + " of " + p.Install + " was interrupted and never finished.\n" +
+			"Recover it first (run the installer again or use the recover command)")
+		Log.Error(err.Error())
+		return err
+	}
+
+	if err = p.save(); err != nil {
+		err = fmt.Errorf("Failed to write journal, not touching anything: %w", err)
+		Log.Error(err.Error())
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		Log.Error(p.Action, "failed. Undoing partial", p.Action)
+		if innerErr := p.RollBack(); innerErr != nil {
+			Log.Error("Failed to undo partial", p.Action+". This install is probably bricked. The journal was kept at", p.journalFile, "so it can be recovered later.", innerErr)
+		} else {
+			Log.Info("Successfully undid all changes")
+		}
+	}()
+
+	return p.RollForward()
+}
+
+// RollForward runs every step that isn't done yet
+func (p *Plan) RollForward() error {
+	for _, step := range p.Steps {
+		if step.Done {
+			continue
+		}
+		if err := step.run(); err != nil {
+			return err
+		}
+		step.Done = true
+		if err := p.save(); err != nil {
+			return fmt.Errorf("Failed to update journal: %w", err)
+		}
+	}
+	p.discard()
+	return nil
+}
+
+// RollBack undoes every step that is done, last one first
+func (p *Plan) RollBack() error {
+	for i := len(p.Steps) - 1; i >= 0; i-- {
+		step := p.Steps[i]
+		if !step.Done {
+			continue
+		}
+		if err := step.undo(); err != nil {
+			return err
+		}
+		step.Done = false
+		if err := p.save(); err != nil {
+			return fmt.Errorf("Failed to update journal: %w", err)
+		}
+	}
+	p.discard()
+	return nil
+}
+
+func (s *Step) run() error {
+	switch s.Kind {
+	case StepRename:
+		Log.Debug("Renaming", s.Source, "to", s.Target)
+		if err := os.Rename(s.Source, s.Target); err != nil {
+			err = CheckIfErrIsCauseItsBusyRn(err)
+			Log.Error(err.Error())
+			return err
+		}
+	case StepWrite:
+		Log.Debug("Writing", len(s.Data), "bytes to", s.Target)
+		if ExistsFile(s.Target) {
+			return errors.New("Not writing " + s.Target + " as it already exists")
+		}
+		if err := os.MkdirAll(path.Dir(s.Target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(s.Target, s.Data, 0644); err != nil {
+			_ = os.Remove(s.Target)
+			return fmt.Errorf("Failed to write %s: %w", s.Target, err)
+		}
+	case StepDownload:
+		Log.Debug("Downloading", s.Url, "to", s.Target)
+		if ExistsFile(s.Target) {
+			return errors.New("Not downloading to " + s.Target + " as it already exists")
+		}
+		if err := downloadFile(s.Url, s.Target); err != nil {
+			_ = os.Remove(s.Target)
+			return err
+		}
+	case StepCopy:
+		Log.Debug("Copying", s.Source, "to", s.Target)
+		if ExistsFile(s.Target) {
+			return errors.New("Not copying " + s.Source + " to " + s.Target + " as it already exists")
+		}
+		if err := os.MkdirAll(path.Dir(s.Target), 0755); err != nil {
+			return err
+		}
+		if err := CopyPath(s.Source, s.Target); err != nil {
+			_ = os.RemoveAll(s.Target)
+			return fmt.Errorf("Failed to copy %s to %s: %w", s.Source, s.Target, err)
+		}
+	case StepCommand:
+		if err := runStepCommand(s.Args, s.RunAs); err != nil {
+			return fmt.Errorf("Failed to %s: %w", Ternary(s.Description != "", s.Description, "run "+strings.Join(s.Args, " ")), err)
+		}
+	case StepInstallDist:
+		if err := installLatestBuilds(); err != nil {
+			return err
+		}
+	case StepRemove:
+		Log.Debug("Deleting", s.Target)
+		if err := os.RemoveAll(s.Target); err != nil {
+			Log.Warn("Failed to delete", s.Target+". This is whatever but you might want to delete it manually.", err)
+		}
+	default:
+		return errors.New("Unknown step " + string(s.Kind))
+	}
+	return nil
+}
+
+func (s *Step) undo() error {
+	switch s.Kind {
+	case StepRename:
+		Log.Debug("Undoing rename of", s.Source, "to", s.Target)
+		if err := os.Rename(s.Target, s.Source); err != nil {
+			return CheckIfErrIsCauseItsBusyRn(err)
+		}
+	case StepWrite, StepDownload, StepCopy:
+		Log.Debug("Undoing write of", s.Target)
+		if err := os.RemoveAll(s.Target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixInterruptedStep fixes up the first step that isn't done. It might have been running when the process died,
+// so it might have (partially) happened even though the journal was never updated
+func (p *Plan) fixInterruptedStep() {
+	for _, s := range p.Steps {
+		if s.Done {
+			continue
+		}
+		switch s.Kind {
+		case StepRename:
+			s.Done = !ExistsFile(s.Source) && ExistsFile(s.Target)
+		case StepWrite, StepDownload, StepCopy:
+			// All previous steps are done, so the target was free before this step started.
+			// Whatever is there now is incomplete
+			if ExistsFile(s.Target) {
+				Log.Debug("Deleting possibly incomplete", s.Target)
+				_ = os.RemoveAll(s.Target)
+			}
+		}
+		return
+	}
+}
+
+func runStepCommand(args []string, runAs string) error {
+	cmd := stepCommand(args, runAs)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// stepCommand builds the command for args, using su to run it as runAs if set
+func stepCommand(args []string, runAs string) *exec.Cmd {
+	fullCmd := strings.Join(args, " ")
+	Log.Debug("Running", fullCmd)
+
+	if runAs != "" {
+		Log.Debug("Using su to run as", runAs)
+		return exec.Command("su", "-", runAs, "-c", "sh", "-c", fullCmd)
+	}
+	return exec.Command(args[0], args[1:]...)
+}
+
+func downloadFile(url, target string) error {
+	res, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return errors.New("Failed to fetch " + url + " - " + strconv.Itoa(res.StatusCode) + ": " + res.Status)
+	}
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, res.Body); err != nil {
+		return err
+	}
+	return out.Close()
+}
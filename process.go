@@ -0,0 +1,104 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TerminateTimeout is how long Discord gets to exit after SIGTERM before it is killed
+const TerminateTimeout = 10 * time.Second
+
+// RunningActions are what can be done about a running Discord before patching it
+var RunningActions = []string{"terminate", "wait", "abort", "ignore"}
+
+// RelaunchDiscord makes Relaunch start Discord again after it was closed to modify it
+var RelaunchDiscord = false
+
+type DiscordProcess struct {
+	Pid  int      `json:"pid"`
+	PPid int      `json:"ppid"`
+	Exe  string   `json:"exe,omitempty"`
+	Args []string `json:"args,omitempty"`
+	// where and with which environment it ran, to start it the same way again
+	Cwd string   `json:"-"`
+	Env []string `json:"-"`
+}
+
+func (p DiscordProcess) String() string {
+	name := p.Exe
+	if name == "" && len(p.Args) > 0 {
+		name = p.Args[0]
+	}
+	return strconv.Itoa(p.Pid) + Ternary(name != "", " ("+name+")", "")
+}
+
+func describeProcesses(procs []DiscordProcess) string {
+	return strings.Join(SliceMap(procs, DiscordProcess.String), ", ")
+}
+
+// rootProcess returns the process of procs that started the others
+func rootProcess(procs []DiscordProcess) DiscordProcess {
+	for _, p := range procs {
+		if !SliceContainsFunc(procs, func(parent DiscordProcess) bool { return parent.Pid == p.PPid }) {
+			return p
+		}
+	}
+	return procs[0]
+}
+
+var (
+	// stoppedInstalls are the installs StopProcesses closed, with the processes they ran
+	stoppedInstalls = map[*DiscordInstall][]DiscordProcess{}
+	stoppedLock     sync.Mutex
+)
+
+// StopProcesses closes procs of di and remembers them, so Relaunch can start di again once it is modified
+func (di *DiscordInstall) StopProcesses(procs []DiscordProcess) error {
+	if err := TerminateProcesses(procs, TerminateTimeout); err != nil {
+		return err
+	}
+	stoppedLock.Lock()
+	defer stoppedLock.Unlock()
+	stoppedInstalls[di] = append(stoppedInstalls[di], procs...)
+	return nil
+}
+
+// Relaunch starts di again if StopProcesses closed it and RelaunchDiscord is set. Returns whether it did
+func (di *DiscordInstall) Relaunch() (bool, error) {
+	stoppedLock.Lock()
+	procs := stoppedInstalls[di]
+	delete(stoppedInstalls, di)
+	stoppedLock.Unlock()
+
+	if !RelaunchDiscord || len(procs) == 0 {
+		return false, nil
+	}
+	if err := di.relaunch(rootProcess(procs)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RelaunchStopped relaunches all installs closed by StopProcesses
+func RelaunchStopped() {
+	stoppedLock.Lock()
+	installs := make([]*DiscordInstall, 0, len(stoppedInstalls))
+	for di := range stoppedInstalls {
+		installs = append(installs, di)
+	}
+	stoppedLock.Unlock()
+
+	for _, di := range installs {
+		if _, err := di.Relaunch(); err != nil {
+			Log.Warn("Failed to restart Discord at", di.path+":", err, "- Start it yourself to load the changes")
+		}
+	}
+}
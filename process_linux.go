@@ -0,0 +1,230 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	path "path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// realUid returns the uid of the user running the installer, or of the user behind sudo when running as root
+func realUid() uint32 {
+	if sudoUser := os.Getenv("SUDO_USER"); os.Geteuid() == 0 && sudoUser != "" {
+		u, err := user.Lookup(sudoUser)
+		if err == nil {
+			uid, _ := strconv.Atoi(u.Uid)
+			return uint32(uid)
+		}
+		Log.Warn("Failed to look up", sudoUser+":", err)
+	}
+	return uint32(os.Getuid())
+}
+
+// isBwrap reports whether exe or the first argument is bubblewrap, which Flatpak starts its sandboxes with
+func isBwrap(exe string, args []string) bool {
+	return SliceContainsFunc([]string{exe, args[0]}, func(s string) bool { return strings.HasSuffix(path.Base(s), "bwrap") })
+}
+
+// RunningProcesses scans /proc for processes of di the user runs: its binary, Electron running its app.asar,
+// or its Flatpak sandbox and everything inside it. Processes of other users are never touched
+func (di *DiscordInstall) RunningProcesses() []DiscordProcess {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		Log.Warn("Failed to list processes:", err)
+		return nil
+	}
+
+	self, _ := os.Executable()
+	uid := realUid()
+	prefixes := SliceMap(di.Paths(), func(p string) string { return p + "/" })
+	appId := Ternary(di.isFlatpak, di.flatpakAppId(), "")
+	matches := func(s string) bool {
+		return SliceContainsFunc(prefixes, func(prefix string) bool { return strings.HasPrefix(s, prefix) })
+	}
+
+	var procs []DiscordProcess
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == os.Getpid() || pid == os.Getppid() {
+			continue
+		}
+		dir := path.Join("/proc", e.Name())
+		if info, err := os.Stat(dir); err != nil {
+			continue
+		} else if st, ok := info.Sys().(*syscall.Stat_t); !ok || st.Uid != uid {
+			continue
+		}
+
+		// only readable for our own processes, unless we're root
+		exe, _ := os.Readlink(path.Join(dir, "exe"))
+		if exe == self {
+			continue
+		}
+		cmdline, _ := os.ReadFile(path.Join(dir, "cmdline"))
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+
+		match := matches(exe) || SliceContainsFunc(args, matches)
+		if !match && appId != "" {
+			// the bwrap setting up the sandbox and everything inside it. A bare argument isn't enough,
+			// that also matches things like an editor opening a file named after the app
+			info, _ := os.ReadFile(path.Join(dir, "root", ".flatpak-info"))
+			match = bytes.Contains(info, []byte("name="+appId+"\n")) ||
+				isBwrap(exe, args) && SliceContainsFunc(args, func(arg string) bool { return strings.Contains(arg, appId) })
+		}
+		if !match {
+			continue
+		}
+		state, ppid := processStat(pid)
+		if state == 0 || state == 'Z' {
+			continue
+		}
+		cwd, _ := os.Readlink(path.Join(dir, "cwd"))
+		environ, _ := os.ReadFile(path.Join(dir, "environ"))
+		procs = append(procs, DiscordProcess{
+			Pid:  pid,
+			PPid: ppid,
+			Exe:  exe,
+			Args: args,
+			Cwd:  cwd,
+			Env:  SliceFilter(strings.Split(string(environ), "\x00"), func(s string) bool { return s != "" }),
+		})
+	}
+	return procs
+}
+
+// processStat returns the state and parent of pid, or 0 as state if it doesn't exist
+func processStat(pid int) (byte, int) {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, 0
+	}
+	// pid (comm) state ppid ...; comm may contain spaces and parentheses
+	i := bytes.LastIndexByte(stat, ')')
+	if i == -1 {
+		return 0, 0
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 2 {
+		return 0, 0
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return fields[0][0], ppid
+}
+
+// processAlive reports whether pid exists and isn't a zombie waiting to be reaped
+func processAlive(pid int) bool {
+	state, _ := processStat(pid)
+	return state != 0 && state != 'Z'
+}
+
+func anyAlive(procs []DiscordProcess) bool {
+	return SliceContainsFunc(procs, func(p DiscordProcess) bool { return processAlive(p.Pid) })
+}
+
+// TerminateProcesses asks procs to exit with SIGTERM, then kills the ones still running after timeout
+func TerminateProcesses(procs []DiscordProcess, timeout time.Duration) error {
+	signal := func(sig syscall.Signal) {
+		for _, p := range procs {
+			if err := syscall.Kill(p.Pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+				Log.Warn("Failed to send", sig.String(), "to", p.Pid, err)
+			}
+		}
+	}
+	waitUntil := func(deadline time.Time) bool {
+		for anyAlive(procs) {
+			if time.Now().After(deadline) {
+				return false
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return true
+	}
+
+	Log.Debug("Terminating", len(procs), "Discord processes")
+	signal(syscall.SIGTERM)
+	if waitUntil(time.Now().Add(timeout)) {
+		return nil
+	}
+
+	Log.Warn("Discord didn't exit within", timeout.String()+", killing it")
+	signal(syscall.SIGKILL)
+	if waitUntil(time.Now().Add(5 * time.Second)) {
+		return nil
+	}
+
+	alive := SliceFilter(procs, func(p DiscordProcess) bool { return processAlive(p.Pid) })
+	return fmt.Errorf("Failed to stop Discord, still running: %s", describeProcesses(alive))
+}
+
+// WaitForProcesses blocks until all procs exited
+func WaitForProcesses(procs []DiscordProcess) {
+	for anyAlive(procs) {
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// relaunch starts di again like root ran it, detached from the installer: Flatpaks through flatpak run,
+// everything else with the original binary and arguments
+func (di *DiscordInstall) relaunch(root DiscordProcess) error {
+	var cmd *exec.Cmd
+	if di.isFlatpak {
+		args, _ := di.flatpakCommand("run", di.flatpakAppId())
+		cmd = exec.Command(args[0], args[1:]...)
+	} else {
+		name := root.Exe
+		if name == "" || strings.HasSuffix(name, " (deleted)") {
+			name = root.Args[0]
+		}
+		cmd = exec.Command(name, root.Args[1:]...)
+		cmd.Args[0] = root.Args[0]
+	}
+	cmd.Dir = root.Cwd
+	if len(root.Env) > 0 {
+		cmd.Env = root.Env
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if sudoUser := os.Getenv("SUDO_USER"); os.Geteuid() == 0 && sudoUser != "" {
+		// don't run Discord as root
+		u, err := user.Lookup(sudoUser)
+		if err != nil {
+			return err
+		}
+		uid, _ := strconv.Atoi(u.Uid)
+		gid, _ := strconv.Atoi(u.Gid)
+		groupIds, _ := u.GroupIds()
+		groups := SliceMap(groupIds, func(id string) uint32 {
+			g, _ := strconv.Atoi(id)
+			return uint32(g)
+		})
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+	}
+
+	Log.Info("Starting Discord:", strings.Join(cmd.Args, " "))
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+func PreparePatch(di *DiscordInstall) error {
+	procs := di.RunningProcesses()
+	if len(procs) == 0 {
+		return nil
+	}
+	Log.Debug(di.path, "is running:", describeProcesses(procs))
+	return HandleRunningDiscord(di, procs)
+}
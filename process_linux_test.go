@@ -0,0 +1,83 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startProcess runs sh as name with args until the test ends and returns its pid
+func startProcess(t *testing.T, name string, args ...string) int {
+	t.Helper()
+	cmd := exec.Command(name, append([]string{"-c", "sleep 30"}, args...)...)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	// until it is running sh, not the test binary
+	for i := 0; i < 50; i++ {
+		if cmdline, _ := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/cmdline"); strings.HasPrefix(string(cmdline), name+"\x00") {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cmd.Process.Pid
+}
+
+func TestRunningProcesses(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	dir := t.TempDir()
+	bwrap := path.Join(dir, "bwrap")
+	if err = os.Symlink(sh, bwrap); err != nil {
+		t.Fatal(err)
+	}
+	native := &DiscordInstall{path: path.Join(dir, "Discord")}
+	flatpak := &DiscordInstall{path: path.Join(dir, "flatpak"), isFlatpak: true, flatpakId: "com.discordapp.Discord"}
+
+	tests := []struct {
+		name    string
+		di      *DiscordInstall
+		sudo    string
+		exe     string
+		args    []string
+		running bool
+	}{
+		{"binary argument", native, "root", sh, []string{native.path + "/Discord"}, true},
+		{"other path", native, "root", sh, []string{dir + "/DiscordCanary/Discord"}, false},
+		{"other user", native, "nobody", sh, []string{native.path + "/Discord"}, false},
+		{"flatpak bwrap", flatpak, "root", bwrap, []string{"/var/lib/flatpak/app/com.discordapp.Discord/current/active/files"}, true},
+		{"flatpak app id argument", flatpak, "root", sh, []string{"com.discordapp.Discord"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if os.Geteuid() == 0 {
+				t.Setenv("SUDO_USER", tt.sudo)
+			} else if tt.sudo != "root" {
+				t.Skip("needs root to see processes of other users")
+			}
+			pid := startProcess(t, tt.exe, tt.args...)
+
+			procs := tt.di.RunningProcesses()
+			if running := SliceContainsFunc(procs, func(p DiscordProcess) bool { return p.Pid == pid }); running != tt.running {
+				t.Errorf("%v found %v, want %v", tt.args, running, tt.running)
+			}
+		})
+	}
+}
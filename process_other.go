@@ -0,0 +1,30 @@
+//go:build !linux
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// RunningProcesses is only implemented on Linux. PreparePatch takes care of Discord on other platforms
+func (di *DiscordInstall) RunningProcesses() []DiscordProcess {
+	return nil
+}
+
+func TerminateProcesses(_ []DiscordProcess, _ time.Duration) error {
+	return nil
+}
+
+func WaitForProcesses(_ []DiscordProcess) {}
+
+// relaunch is never called, as StopProcesses never stops anything here and --relaunch is rejected
+func (di *DiscordInstall) relaunch(_ DiscordProcess) error {
+	return errors.New("Restarting Discord is only supported on Linux")
+}
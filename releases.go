@@ -0,0 +1,174 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// LatestVersion is the version that unpins a source, so it installs its latest release again
+const LatestVersion = "latest"
+
+// PinnedBuild is the kept build the pinned version of SelectedSource resolved to. Installing uses it instead of
+// downloading, as rolling tags like devbuild only ever offer their latest build
+var PinnedBuild *Build
+
+// Hash returns the commit a release was built from. Vencord names its releases like "Devbuild abc1234"
+func (r *GithubRelease) Hash() string {
+	return r.Name[strings.LastIndex(r.Name, " ")+1:]
+}
+
+var commitHashRe = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// Version returns the version to pin r by: its commit hash if its name has one, as tags like devbuild move on
+// to newer builds, otherwise its tag
+func (r *GithubRelease) Version() string {
+	if hash := r.Hash(); commitHashRe.MatchString(hash) {
+		return hash
+	}
+	return r.TagName
+}
+
+// Matches reports whether the release is version, given as tag or commit hash. Hashes may be shortened to
+// 7 characters, like git does
+func (r *GithubRelease) Matches(version string) bool {
+	if r.TagName == version {
+		return true
+	}
+	return len(version) >= 7 && (strings.HasPrefix(r.Hash(), version) || strings.HasPrefix(r.TargetCommitish, version))
+}
+
+// requestedVersions are the versions picked in this run, by source id. They are only pinned once installing or
+// repairing with them worked, so dry runs and failed installs don't change what later repairs install
+var requestedVersions = map[string]string{}
+
+// PinnedVersion returns the tag or commit hash src is pinned to, "" if it installs its latest release. A version
+// requested in this run takes precedence
+func PinnedVersion(src *Source) string {
+	if version, ok := requestedVersions[src.Id]; ok {
+		return Ternary(version == LatestVersion, "", version)
+	}
+	return GetState().PinnedVersions[src.Id]
+}
+
+// RequestVersion makes src install version in this run. SavePinnedVersions pins it once that worked.
+// LatestVersion unpins it
+func RequestVersion(src *Source, version string) {
+	requestedVersions[src.Id] = Ternary(version == "", LatestVersion, version)
+}
+
+// SavePinnedVersions pins the versions requested in this run, so repairs stick to them from now on. Call it once
+// installing or repairing with them worked
+func SavePinnedVersions() error {
+	if len(requestedVersions) == 0 {
+		return nil
+	}
+	return UpdateState(func(s *InstallerState) {
+		for id, version := range requestedVersions {
+			if version == LatestVersion {
+				delete(s.PinnedVersions, id)
+				continue
+			}
+			if s.PinnedVersions == nil {
+				s.PinnedVersions = make(map[string]string)
+			}
+			s.PinnedVersions[id] = version
+		}
+	})
+}
+
+// releasesUrl returns the API url listing the releases of s, if it is a GitHub repo
+func (s *Source) releasesUrl() (string, error) {
+	url, ok := strings.CutSuffix(s.ReleaseUrl, "/releases/latest")
+	if !ok {
+		return "", errors.New("Can't list the releases of " + s.Name + ", only GitHub repos have a list")
+	}
+	return url + "/releases", nil
+}
+
+// ListReleases fetches the most recent releases of src, newest first
+func ListReleases(src *Source) ([]GithubRelease, error) {
+	url, err := src.releasesUrl()
+	if err != nil {
+		return nil, err
+	}
+	Log.Debug("Fetching", url)
+
+	req, err := http.NewRequest("GET", url+"?per_page=100", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return nil, errors.New(url + " returned " + res.Status)
+	}
+
+	var releases []GithubRelease
+	if err = json.NewDecoder(res.Body).Decode(&releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// FindRelease returns the release of src with the given tag or commit hash
+func FindRelease(src *Source, version string) (*GithubRelease, error) {
+	releases, err := ListReleases(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range releases {
+		if r.Matches(version) {
+			return &r, nil
+		}
+	}
+
+	// Older than the list goes back
+	if url, _ := src.releasesUrl(); !commitHashRe.MatchString(version) {
+		if r, err := GetGithubRelease(url+"/tags/"+version, ""); err == nil {
+			return r, nil
+		}
+	}
+	return nil, errors.New(src.Name + " has no release " + version)
+}
+
+// ResolveVersion returns what to pin src to for version, given as tag, commit hash or id of a kept build: the
+// commit hash of that release, so pinning a tag like devbuild holds once it moves on to newer builds
+func ResolveVersion(src *Source, version string) (string, error) {
+	if version == "" || version == LatestVersion {
+		return LatestVersion, nil
+	}
+	// Kept builds can be pinned offline
+	if b := pinnedBuild(src, version); b != nil {
+		return Ternary(commitHashRe.MatchString(b.Hash), b.Hash, b.Id), nil
+	}
+	r, err := FindRelease(src, version)
+	if err != nil {
+		return "", err
+	}
+	return r.Version(), nil
+}
+
+// pinnedBuild returns the kept build of src with the given id or commit hash, if there is one. Tags aren't
+// matched, as they might have moved on since
+func pinnedBuild(src *Source, version string) *Build {
+	for _, b := range ListBuilds() {
+		if b.Source == src.Id && b.matches(version) {
+			return b
+		}
+	}
+	return nil
+}
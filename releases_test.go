@@ -0,0 +1,64 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRequestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		pinned  string
+		request string
+		// what installs in this run and what is pinned once that worked
+		want, wantSaved string
+	}{
+		{"nothing", "", "", "", ""},
+		{"keep pin", "abc1234", "", "abc1234", "abc1234"},
+		{"pin", "", "abc1234", "abc1234", "abc1234"},
+		{"repin", "abc1234", "def5678", "def5678", "def5678"},
+		{"unpin", "abc1234", LatestVersion, "", ""},
+	}
+
+	src := DefaultSource
+	reload := func() string {
+		state, stateOnce = nil, sync.Once{}
+		return GetState().PinnedVersions[src.Id]
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempBaseDir(t)
+			requestedVersions = map[string]string{}
+			t.Cleanup(func() { requestedVersions = map[string]string{} })
+			if err := UpdateState(func(s *InstallerState) {
+				s.PinnedVersions = map[string]string{src.Id: tt.pinned}
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.request != "" {
+				RequestVersion(src, tt.request)
+			}
+			if got := PinnedVersion(src); got != tt.want {
+				t.Errorf("installs %q, want %q", got, tt.want)
+			}
+			if got := reload(); got != tt.pinned {
+				t.Errorf("pinned %q before installing, want %q", got, tt.pinned)
+			}
+
+			if err := SavePinnedVersions(); err != nil {
+				t.Fatal(err)
+			}
+			if got := reload(); got != tt.wantSaved {
+				t.Errorf("pinned %q after installing, want %q", got, tt.wantSaved)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Source is a place Vencord builds are downloaded from, like the VencordJP fork or upstream Vencord
+type Source struct {
+	Id   string
+	Name string
+	// GitHub API url of the release to install
+	ReleaseUrl string
+	// Tried if ReleaseUrl is rate limited or blocked. Optional
+	FallbackUrl string
+	// Name prefixes of the release assets that make up the dist
+	Assets []string
+}
+
+var vencordAssets = []string{"patcher.js", "preload.js", "renderer.js", "renderer.css"}
+
+var Sources = []*Source{
+	{
+		Id:         "vencordjp",
+		Name:       "VencordJP",
+		ReleaseUrl: "https://api.github.com/repos/VencordJP/Vencord/releases/latest",
+		// vencord.dev only mirrors upstream, so there is no fallback. Installing upstream instead would be a surprise
+		Assets: vencordAssets,
+	},
+	{
+		Id:          "vencord",
+		Name:        "Vencord",
+		ReleaseUrl:  "https://api.github.com/repos/Vendicated/Vencord/releases/latest",
+		FallbackUrl: "https://vencord.dev/releases/vencord",
+		Assets:      vencordAssets,
+	},
+}
+
+var DefaultSource = Sources[0]
+
+// SelectedSource is the source the next install or repair downloads from. Defaults to InstalledSource
+var SelectedSource *Source
+
+var githubRepoRe = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// ParseSource returns the built-in source with the given id, or a custom source for a GitHub fork given as
+// owner/repo or as the API url of its release
+func ParseSource(s string) (*Source, error) {
+	for _, src := range Sources {
+		if strings.EqualFold(src.Id, s) {
+			return src, nil
+		}
+	}
+
+	if githubRepoRe.MatchString(s) {
+		return &Source{
+			Id:         s,
+			Name:       s,
+			ReleaseUrl: "https://api.github.com/repos/" + s + "/releases/latest",
+			Assets:     vencordAssets,
+		}, nil
+	}
+
+	if strings.HasPrefix(s, "https://") {
+		return &Source{
+			Id:         s,
+			Name:       strings.TrimPrefix(s, "https://"),
+			ReleaseUrl: s,
+			Assets:     vencordAssets,
+		}, nil
+	}
+
+	return nil, errors.New("Unknown source '" + s + "'. Must be " +
+		strings.Join(SliceMap(Sources, func(src *Source) string { return src.Id }), ", ") +
+		", a GitHub fork as owner/repo or a release url")
+}
+
+// installedSourceId returns the id of InstalledSource without looking it up
+func installedSourceId() string {
+	if id := GetState().Source; id != "" {
+		return id
+	}
+	return DefaultSource.Id
+}
+
+// InstalledSource returns the source the dist in FilesDir was downloaded from
+func InstalledSource() *Source {
+	id := GetState().Source
+	if id == "" {
+		// Installed before sources were a thing
+		return DefaultSource
+	}
+	src, err := ParseSource(id)
+	if err != nil {
+		Log.Warn("Ignoring recorded source:", err)
+		return DefaultSource
+	}
+	return src
+}
+
+func (s *Source) IsCustom() bool {
+	return !SliceContains(Sources, s)
+}
+
+func (s *Source) String() string {
+	return s.Name
+}
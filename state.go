@@ -0,0 +1,85 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	path "path/filepath"
+	"sync"
+)
+
+// InstallerState is everything we need to remember between runs. It is stored as state.json in BaseDir
+type InstallerState struct {
+	// Id of the source the dist in FilesDir was downloaded from, see ParseSource
+	Source string `json:"source,omitempty"`
+	// sha256 of every file in the dist, by name, as downloaded
+	DistHashes map[string]string `json:"distHashes,omitempty"`
+	// FilesDir each Flatpak was granted access to by patching it, by installation and app id like
+	// user:com.discordapp.Discord
+	FlatpakOverrides map[string]string `json:"flatpakOverrides,omitempty"`
+	// Id of the kept build in FilesDir, see Build
+	ActiveBuild string `json:"activeBuild,omitempty"`
+	// How many builds to keep, DefaultKeepBuilds if unset
+	KeepBuilds int `json:"keepBuilds,omitempty"`
+	// Release tag or commit hash each source is pinned to by id. Sources that aren't install their latest release
+	PinnedVersions map[string]string `json:"pinnedVersions,omitempty"`
+}
+
+var (
+	state     *InstallerState
+	stateOnce sync.Once
+	stateLock sync.Mutex
+)
+
+func stateFile() string {
+	return path.Join(BaseDir, "state.json")
+}
+
+// GetState returns the installer state, reading it from disk the first time
+func GetState() *InstallerState {
+	stateOnce.Do(func() {
+		state = &InstallerState{}
+		b, err := os.ReadFile(stateFile())
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				Log.Warn("Failed to read", stateFile()+":", err)
+			}
+			return
+		}
+		if err = json.Unmarshal(b, state); err != nil {
+			Log.Warn("Ignoring corrupt", stateFile()+":", err)
+			state = &InstallerState{}
+		}
+	})
+	return state
+}
+
+// UpdateState applies update to the state and writes it to disk
+func UpdateState(update func(s *InstallerState)) error {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	s := GetState()
+	update(s)
+
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := stateFile() + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, stateFile()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return FixOwnership(stateFile())
+}
@@ -0,0 +1,162 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	path "path/filepath"
+	"strings"
+)
+
+// storeProfileBins are the bin folders of Nix and Guix profiles. They only contain symlinks into the store,
+// often to wrapper scripts
+func storeProfileBins() []string {
+	userName := os.Getenv("SUDO_USER")
+	if userName == "" {
+		userName = os.Getenv("USER")
+	}
+	return []string{
+		path.Join(Home, ".nix-profile/bin"),
+		path.Join(Home, ".local/state/nix/profile/bin"),
+		path.Join("/etc/profiles/per-user", userName, "bin"),
+		"/run/current-system/sw/bin",
+		"/nix/var/nix/profiles/default/bin",
+		path.Join(Home, ".guix-profile/bin"),
+		path.Join(Home, ".guix-home/profile/bin"),
+		"/run/current-system/profile/bin",
+	}
+}
+
+// storeOf returns nix or guix if p is inside their read-only store
+func storeOf(p string) string {
+	if resolved, err := path.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	switch {
+	case strings.HasPrefix(p, "/nix/store/"):
+		return "nix"
+	case strings.HasPrefix(p, "/gnu/store/"):
+		return "guix"
+	default:
+		return ""
+	}
+}
+
+// storeProfileInstalls returns the installs the Nix and Guix profiles of the user and system link to
+func storeProfileInstalls() []installCandidate {
+	var candidates []installCandidate
+	for _, bin := range storeProfileBins() {
+		for _, name := range launcherNames {
+			if root := resolveLauncher(path.Join(bin, name), 3); root != "" {
+				candidates = append(candidates, installCandidate{root, GetBranch(name)})
+			}
+		}
+	}
+	return candidates
+}
+
+// nixPackageNames are the nixpkgs attributes of the Discord branches
+var nixPackageNames = map[string]string{
+	"stable":      "discord",
+	"ptb":         "discord-ptb",
+	"canary":      "discord-canary",
+	"development": "discord-development",
+}
+
+// StoreSnippet returns config that makes the store itself install Vencord into di
+func (di *DiscordInstall) StoreSnippet() (string, error) {
+	if di.store != "nix" {
+		return "", errors.New("Only Nix can install Vencord itself. Patch a writable copy with the store copy command instead")
+	}
+
+	pkg := nixPackageNames[di.branch]
+	return fmt.Sprintf(`# Add this to your configuration.nix, or to home.nix using home.packages instead
+environment.systemPackages = [
+  (pkgs.%s.override {
+    withVencord = true;
+  })
+];
+# Then remove the plain %s package and rebuild. This installs upstream Vencord, which nixpkgs packages`, pkg, pkg), nil
+}
+
+// storeCopyName is the name the writable copy of di gets. It ends with the branch so it is detected as such
+func (di *DiscordInstall) storeCopyName() string {
+	return "vencord-" + Ternary(di.branch == "stable", "discord", "discord-"+di.branch)
+}
+
+// StoreCopyDir is where PlanStoreCopy copies di to by default
+func (di *DiscordInstall) StoreCopyDir() string {
+	return path.Join(Home, ".local/share", di.storeCopyName())
+}
+
+// storeLauncher returns the file in the store install that starts Discord, and whether it is a wrapper script
+// that refers to the install by its path
+func (di *DiscordInstall) storeLauncher() (string, bool) {
+	entries, err := os.ReadDir(di.path)
+	if err != nil {
+		return "", false
+	}
+
+	binary := ""
+	for _, e := range entries {
+		file := path.Join(di.path, e.Name())
+		info, err := os.Stat(file)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		if script := readScript(file); script != nil && bytes.Contains(script, []byte(di.path)) {
+			return file, true
+		}
+		if binary == "" && SliceContains(LinuxDiscordNames, e.Name()) {
+			binary = file
+		}
+	}
+	return binary, false
+}
+
+// PlanStoreCopy plans copying di out of the read-only store to dir, where it can be patched. A desktop entry
+// starting the copy is added so it shows up in the app menu, and so we find it again
+func (di *DiscordInstall) PlanStoreCopy(dir string) (*Plan, error) {
+	if di.store == "" {
+		return nil, errors.New(di.path + " is not in a read-only store, patch it directly")
+	}
+	if ExistsFile(dir) {
+		return nil, errors.New(dir + " already exists. Patch it with --location " + dir)
+	}
+
+	launcher, isScript := di.storeLauncher()
+	if launcher == "" {
+		return nil, errors.New("Couldn't find the Discord binary in " + di.path)
+	}
+
+	plan := NewPlan("store copy", di)
+	plan.Copy(di.path, dir).Description = "writable copy of Discord " + di.branch
+
+	exec := `"` + path.Join(dir, path.Base(launcher)) + `"`
+	if isScript {
+		script, err := os.ReadFile(launcher)
+		if err != nil {
+			return nil, err
+		}
+		// the wrapper sets up the environment, then starts the binary in the store. Start the copy instead
+		newLauncher := path.Join(dir, "vencord-launcher")
+		plan.Write(newLauncher, bytes.ReplaceAll(script, []byte(di.path), []byte(dir))).Description = "copy of " + launcher + " starting the copy"
+		// Written files aren't executable, so run it with the interpreter from its shebang
+		shebang, _, _ := strings.Cut(string(script[2:]), "\n")
+		exec = strings.TrimSpace(shebang) + ` "` + newLauncher + `"`
+	}
+
+	//goland:noinspection GoDeprecation
+	name := strings.Title(strings.ReplaceAll(di.storeCopyName(), "-", " "))
+	entry := fmt.Sprintf("[Desktop Entry]\nType=Application\nName=%s\nComment=Writable copy of %s\nExec=%s\nPath=%s\nIcon=%s\nCategories=Network;InstantMessaging;\n",
+		name, di.path, exec, dir, nixPackageNames[di.branch])
+	plan.Write(path.Join(Home, ".local/share/applications", di.storeCopyName()+".desktop"), []byte(entry)).Description = "app menu entry for the copy"
+	return plan, nil
+}
@@ -0,0 +1,39 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"sync"
+	"testing"
+)
+
+// useTempBaseDir points BaseDir, FilesDir and the state at a fresh folder for the duration of the test
+func useTempBaseDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	oldBase, oldFiles, oldPatcher := BaseDir, FilesDir, Patcher
+	BaseDir, FilesDir, Patcher = dir, path.Join(dir, "dist"), path.Join(dir, "dist", "patcher.js")
+	state, stateOnce = nil, sync.Once{}
+	t.Cleanup(func() {
+		BaseDir, FilesDir, Patcher = oldBase, oldFiles, oldPatcher
+		state, stateOnce = nil, sync.Once{}
+	})
+	return dir
+}
+
+// writeFile creates the file p with content, making its folder if needed
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
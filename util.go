@@ -7,9 +7,16 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
+	"io/fs"
 	"os"
+	path "path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -51,6 +58,16 @@ func ExistsFile(path string) bool {
 	return err == nil
 }
 
+// unusedPath returns p, or p with a number appended if p already exists, so files that might be the only copy
+// of something, like the safety copy an earlier restore left behind, are never overwritten
+func unusedPath(p string) string {
+	unused := p
+	for i := 2; ExistsFile(unused); i++ {
+		unused = p + "." + strconv.Itoa(i)
+	}
+	return unused
+}
+
 func IsDirectory(path string) bool {
 	s, err := os.Stat(path)
 	if err != nil {
@@ -105,3 +122,121 @@ func CheckIfErrIsCauseItsBusyRn(err error) error {
 func Prepend[T any](slice []T, elems ...T) []T {
 	return append(elems, slice...)
 }
+
+func SliceFilter[T any](slice []T, fn func(T) bool) []T {
+	var result []T
+	for _, e := range slice {
+		if fn(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// CopyPath copies the file or directory tree at src to dst, keeping file modes and symlinks
+func CopyPath(src, dst string) error {
+	return path.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := path.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := path.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(p, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// HashPath returns the hex sha256 of a file. For directories, it hashes the name and hash of every file
+// inside it, so two trees only have the same hash if they have the same files with the same contents
+func HashPath(p string) (string, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return hashFile(p)
+	}
+
+	var lines []string
+	err = path.WalkDir(p, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := path.Rel(p, file)
+		if err != nil {
+			return err
+		}
+
+		var hash string
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+			hash = "link:" + link
+		} else if hash, err = hashFile(file); err != nil {
+			return err
+		}
+		lines = append(lines, path.ToSlash(rel)+"\x00"+hash)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
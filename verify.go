@@ -0,0 +1,114 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	path "path/filepath"
+	"vencordinstaller/asar"
+)
+
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckFail CheckStatus = "fail"
+	// CheckSkip means the check doesn't apply or there is nothing to compare against
+	CheckSkip CheckStatus = "skip"
+)
+
+type VerifyCheck struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+type VerifyReport struct {
+	Install string         `json:"install"`
+	Ok      bool           `json:"ok"`
+	Checks  []*VerifyCheck `json:"checks"`
+}
+
+func (r *VerifyReport) add(name string, status CheckStatus, detail string) {
+	r.Checks = append(r.Checks, &VerifyCheck{Name: name, Status: status, Detail: detail})
+	if status == CheckFail {
+		r.Ok = false
+	}
+}
+
+func (r *VerifyReport) check(name string, err error, passDetail string) {
+	if err != nil {
+		r.add(name, CheckFail, err.Error())
+	} else {
+		r.add(name, CheckPass, passDetail)
+	}
+}
+
+// Verify checks that di is completely patched: the stub loads the current patcher, Discord's original
+// app.asar is still around, the dist is intact and the Flatpak may read it
+func (di *DiscordInstall) Verify() *VerifyReport {
+	r := &VerifyReport{Install: di.path, Ok: true}
+	appAsar := di.appAsar()
+	_appAsar := di.originalAsar()
+
+	if target, err := ReadStubTarget(appAsar); err != nil {
+		r.add("stub", CheckFail, err.Error())
+	} else if target == "" {
+		r.add("stub", CheckFail, appAsar+" is not a stub, Discord probably updated and replaced it")
+	} else if patcher := di.patcherPath(); target != patcher {
+		r.add("stub", CheckFail, appAsar+" requires "+target+" instead of "+patcher)
+	} else {
+		r.add("stub", CheckPass, appAsar+" requires "+patcher)
+	}
+
+	if a, err := asar.Open(_appAsar); err != nil {
+		r.add("original", CheckFail, err.Error())
+	} else {
+		_ = a.Close()
+		r.check("original", Ternary(IsStubAsar(_appAsar), fmt.Errorf("%s is a stub as well, Discord's original app.asar is gone", _appAsar), nil), _appAsar)
+	}
+
+	hashes := GetState().DistHashes
+	for _, name := range InstalledSource().Assets {
+		file := path.Join(FilesDir, name)
+		hash, err := HashPath(file)
+		if err != nil {
+			r.add("dist/"+name, CheckFail, err.Error())
+			continue
+		}
+
+		expected, ok := hashes[name]
+		switch {
+		case !ok:
+			r.add("dist/"+name, CheckSkip, "exists, but there is no recorded hash to compare against"+Ternary(IsDevInstall, " (dev install)", ""))
+		case hash != expected:
+			r.add("dist/"+name, CheckFail, fmt.Sprintf("sha256 is %s, expected %s. It was modified after downloading", hash, expected))
+		default:
+			r.add("dist/"+name, CheckPass, "sha256 "+hash)
+		}
+	}
+
+	pkgJson := path.Join(FilesDir, "package.json")
+	r.check("package.json", Ternary(ExistsFile(pkgJson), nil, fmt.Errorf("%s is missing, node might pick up an unrelated package.json", pkgJson)), pkgJson)
+
+	if di.isFlatpak && di.CurrentFlatpakMode() == FlatpakModeSandbox {
+		problem := di.sandboxDistProblem()
+		r.check("flatpak-sandbox", Ternary(problem != "", errors.New(problem), nil), di.sandboxFilesDir()+" matches "+FilesDir)
+		r.add("flatpak-override", CheckSkip, "the sandbox mode doesn't need it")
+	} else if di.isFlatpak {
+		if ok, err := di.HasFlatpakOverride(FilesDir); err != nil {
+			r.add("flatpak-override", CheckFail, "failed to read overrides: "+err.Error())
+		} else {
+			r.check("flatpak-override", Ternary(ok, nil, fmt.Errorf("%s may not read %s", di.flatpakAppId(), FilesDir)), di.flatpakAppId()+" may read "+FilesDir)
+		}
+	} else {
+		r.add("flatpak-override", CheckSkip, "not a Flatpak")
+	}
+
+	return r
+}
@@ -0,0 +1,274 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+	path "path/filepath"
+	"strings"
+	"time"
+	"vencordinstaller/asar"
+)
+
+type WatchOptions struct {
+	// How long the resources dir has to be quiet before we look at it. Updates write app.asar in several steps
+	Settle time.Duration
+	// How often a failing re-patch is retried before giving up until the next change
+	Retries int
+	// Upper bound for the delay between retries, which doubles with every failed attempt
+	MaxBackoff time.Duration
+}
+
+// dirWatcher reports changes in directories. Implemented with inotify on Linux, see watcher_linux.go
+type dirWatcher interface {
+	Add(dir string) error
+	// Events receives the directory something changed in. Lost receives directories that were deleted or
+	// moved away, they are no longer watched after that
+	Events() <-chan string
+	Lost() <-chan string
+	Close() error
+}
+
+type watchedInstall struct {
+	di *DiscordInstall
+	// asarDir of di with all symlinks resolved, inotify would keep watching the folder they pointed to before
+	dir      string
+	timer    *time.Timer
+	attempts int
+	lost     bool
+	// the whole dir was replaced, so _app.asar being gone doesn't mean it was unpatched
+	replaced bool
+}
+
+// resolveDir returns the folder the asarDir of w.di currently leads to, "" if it doesn't exist right now
+func (w *watchedInstall) resolveDir() string {
+	dir, err := path.EvalSymlinks(w.di.asarDir())
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// symlinkDirs returns the folders containing the symlinks on the way to dir, resolved, like the ones holding the
+// current and active symlinks of a Flatpak. Updates replace those symlinks to point to the new version
+func symlinkDirs(dir string) []string {
+	var dirs []string
+	p := string(os.PathSeparator)
+	for _, part := range strings.Split(strings.Trim(dir, string(os.PathSeparator)), string(os.PathSeparator)) {
+		p = path.Join(p, part)
+		if info, err := os.Lstat(p); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if parent, err := path.EvalSymlinks(path.Dir(p)); err == nil && !SliceContains(dirs, parent) {
+			dirs = append(dirs, parent)
+		}
+	}
+	return dirs
+}
+
+// WasPatched reports whether di is patched or was patched before Discord replaced the stub with a new app.asar
+func (di *DiscordInstall) WasPatched() bool {
+	return di.IsPatched() || ExistsFile(di.originalAsar())
+}
+
+// Watch keeps installs patched until stop is closed. Whenever Discord replaces the stub app.asar with a new
+// vanilla one, the install is patched again once the writes have settled
+func Watch(installs []*DiscordInstall, opts WatchOptions, stop <-chan struct{}) error {
+	watcher, err := newDirWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	due := make(chan *watchedInstall)
+	schedule := func(w *watchedInstall, d time.Duration) {
+		if w.timer != nil {
+			w.timer.Stop()
+		}
+		w.timer = time.AfterFunc(d, func() {
+			select {
+			case due <- w:
+			case <-stop:
+			}
+		})
+	}
+
+	watched := make(map[string]*watchedInstall)
+	// the installs behind the symlinks in each folder
+	links := make(map[string][]*watchedInstall)
+	watchLinks := func(w *watchedInstall) {
+		for _, dir := range symlinkDirs(w.di.asarDir()) {
+			if SliceContains(links[dir], w) {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				Log.Warn("Failed to watch", dir, "for updates replacing", w.di.path+":", err)
+				continue
+			}
+			links[dir] = append(links[dir], w)
+		}
+	}
+	// follow moves w to the folder its symlinks lead to now. Returns false if they still lead to the same one
+	follow := func(w *watchedInstall) bool {
+		dir := w.resolveDir()
+		if dir == "" || dir == w.dir {
+			return false
+		}
+		if err := watcher.Add(dir); err != nil {
+			Log.Warn("Failed to watch", dir+":", err)
+			return false
+		}
+		Log.Info(w.di.path, "now leads to", dir+", watching it instead of", w.dir)
+		delete(watched, w.dir)
+		w.dir = dir
+		watched[dir] = w
+		w.lost = false
+		w.replaced = true
+		watchLinks(w)
+		return true
+	}
+
+	for _, di := range installs {
+		w := &watchedInstall{di: di}
+		if w.dir = w.resolveDir(); w.dir == "" {
+			return errors.New(di.asarDir() + " doesn't exist")
+		}
+		if err = watcher.Add(w.dir); err != nil {
+			return errors.New("Failed to watch " + w.dir + ": " + err.Error())
+		}
+		watched[w.dir] = w
+		watchLinks(w)
+		Log.Info("Watching", di.path)
+		// Discord might have updated while nobody was watching
+		schedule(w, 0)
+	}
+	defer func() {
+		for _, w := range watched {
+			if w.timer != nil {
+				w.timer.Stop()
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case dir := <-watcher.Events():
+			if w, ok := watched[dir]; ok {
+				Log.Debug("Change in", dir+", waiting", opts.Settle, "for it to settle")
+				schedule(w, opts.Settle)
+			}
+			for _, w := range links[dir] {
+				if follow(w) {
+					schedule(w, opts.Settle)
+				}
+			}
+		case dir := <-watcher.Lost():
+			if w, ok := watched[dir]; ok {
+				// Package managers tend to replace the whole install dir, wait for the new one to show up
+				Log.Info(dir, "was removed, waiting for it to come back")
+				w.lost = true
+				schedule(w, opts.Settle)
+			}
+		case w := <-due:
+			if w.lost {
+				// Updates that replace symlinks delete the folder they pointed to before
+				if follow(w) {
+					schedule(w, opts.Settle)
+					continue
+				}
+				if !ExistsFile(w.dir) {
+					schedule(w, opts.Settle)
+					continue
+				}
+				if err = watcher.Add(w.dir); err != nil {
+					Log.Warn("Failed to watch", w.dir+":", err)
+					schedule(w, opts.Settle)
+					continue
+				}
+				Log.Info(w.dir, "is back, watching it again")
+				w.lost = false
+				w.replaced = true
+				// Files might have been written before we were watching again. Check once more after the settle time
+				schedule(w, opts.Settle)
+				continue
+			}
+
+			if err = w.check(); err == nil {
+				w.attempts = 0
+				w.replaced = false
+				continue
+			}
+
+			w.attempts++
+			if w.attempts > opts.Retries {
+				Log.Error("Giving up on re-patching", w.di.path, "after", opts.Retries, "retries. Will try again on the next change.", err)
+				w.attempts = 0
+				continue
+			}
+
+			backoff := opts.Settle << w.attempts
+			if backoff <= 0 || backoff > opts.MaxBackoff {
+				backoff = opts.MaxBackoff
+			}
+			Log.Warn("Re-patching", w.di.path, "failed, retrying in", backoff.String()+":", err)
+			schedule(w, backoff)
+		}
+	}
+}
+
+// check patches the install again if Discord replaced the stub. Returns an error if that should be retried
+func (w *watchedInstall) check() error {
+	di := w.di
+	appAsar := di.appAsar()
+
+	if IsOwnStubAsar(appAsar) {
+		Log.Debug(di.path, "is still patched")
+		return nil
+	}
+
+	if m := di.DetectForeignMod(); m != nil {
+		Log.Warn("Not re-patching", di.path, "because it is now modified by", m.String())
+		return nil
+	}
+
+	if pending := FindPendingPlan(di.Paths()...); pending != nil {
+		// Someone else (or us, in a previous life) is in the middle of something. Don't make it worse
+		Log.Warn("Not re-patching", di.path, "because an earlier", pending.Action, "was interrupted. Run the recover command")
+		return nil
+	}
+
+	if !w.replaced && !ExistsFile(di.originalAsar()) {
+		Log.Info(di.path, "was unpatched, not re-patching it")
+		return nil
+	}
+
+	// While Discord is updating, app.asar may be missing or half written
+	if !ExistsFile(appAsar) {
+		return errors.New(appAsar + " is missing, Discord is probably still updating")
+	}
+	a, err := asar.Open(appAsar)
+	if err != nil {
+		return errors.New(appAsar + " is not a valid asar yet, Discord is probably still updating: " + err.Error())
+	}
+	_ = a.Close()
+
+	di.refreshState()
+	if di.status.State == StateOpenAsar {
+		Log.Info(di.path, "now has OpenAsar instead of the stub, leaving it alone")
+		return nil
+	}
+
+	Log.Info("Discord replaced the patch of", di.path+", re-patching")
+	if err = di.patch(); err != nil {
+		return err
+	}
+	Log.Info("Re-patched", di.path)
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"sync"
+	"unsafe"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+type inotifyWatcher struct {
+	fd     int
+	file   *os.File
+	done   chan struct{}
+	mu     sync.Mutex
+	dirs   map[int32]string
+	events chan string
+	lost   chan string
+}
+
+func newDirWatcher() (dirWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.New("Failed to set up inotify: " + err.Error())
+	}
+
+	w := &inotifyWatcher{
+		// Non blocking, so reads go through the runtime poller and Close interrupts them
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		done:   make(chan struct{}),
+		dirs:   make(map[int32]string),
+		events: make(chan string),
+		lost:   make(chan string),
+	}
+	go w.read()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Add(dir string) error {
+	wd, err := unix.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *inotifyWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *inotifyWatcher) Lost() <-chan string {
+	return w.lost
+}
+
+func (w *inotifyWatcher) Close() error {
+	close(w.done)
+	return w.file.Close()
+}
+
+func (w *inotifyWatcher) read() {
+	var buf [unix.SizeofInotifyEvent * 256]byte
+	for {
+		n, err := w.file.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				Log.Error("Failed to read inotify events:", err)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			w.mu.Lock()
+			dir, ok := w.dirs[event.Wd]
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, event.Wd)
+			}
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			switch {
+			case event.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0:
+				if event.Mask&unix.IN_MOVE_SELF != 0 {
+					// A moved dir is still watched under its new name, which we don't care about
+					_, _ = unix.InotifyRmWatch(w.fd, uint32(event.Wd))
+				}
+				w.send(w.lost, dir)
+			case event.Mask&unix.IN_IGNORED == 0:
+				w.send(w.events, dir)
+			}
+		}
+	}
+}
+
+func (w *inotifyWatcher) send(ch chan string, dir string) {
+	select {
+	case ch <- dir:
+	case <-w.done:
+	}
+}